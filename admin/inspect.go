@@ -15,6 +15,7 @@ const (
 	queryKeyInterface    = "interface"
 	queryKeyServiceId0   = "service-id"
 	queryKeyServiceId1   = "service"
+	queryKeyInspectName  = "name"
 )
 
 type EndpointFilter func(ep *flux.MultiEndpoint) bool
@@ -101,6 +102,23 @@ func InspectServicesHandler(ctx flux.WebContext) error {
 	})
 }
 
+// InspectStatesHandler 查询组件注册的内部状态；未指定名称时，返回可查询的名称列表
+func InspectStatesHandler(ctx flux.WebContext) error {
+	noheader := http.Header{}
+	name := ctx.QueryVar(queryKeyInspectName)
+	if "" == name {
+		return ctx.Send(ctx, noheader, flux.StatusOK, ext.GetInspectNames())
+	}
+	if f, ok := ext.GetInspectFunc(name); ok {
+		return ctx.Send(ctx, noheader, flux.StatusOK, f())
+	}
+	return ctx.Send(ctx, noheader, flux.StatusNotFound, map[string]string{
+		"status":  "failed",
+		"message": "inspect name not found",
+		"name":    name,
+	})
+}
+
 func queryWithEndpointFilters(data map[string]*flux.MultiEndpoint, filters ...EndpointFilter) []map[string]*flux.Endpoint {
 	items := make([]map[string]*flux.Endpoint, 0, 16)
 DataLoop:
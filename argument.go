@@ -2,6 +2,37 @@ package flux
 
 import "fmt"
 
+const (
+	// VarArgumentRecorder Context中记录主服务Argument解析结果的 ArgumentRecorder 的Variable键
+	VarArgumentRecorder = "flux.argument.recorder"
+)
+
+// ArgumentRecorder 记录主服务调用中顶层Argument的解析结果，例如流量镜像复用主服务实际发送的参数值
+type ArgumentRecorder interface {
+	Record(name string, value interface{})
+}
+
+// RecordArguments 返回解析时记录参数值的Argument列表，不修改原列表；
+// 只记录顶层参数的解析结果，按子结构字段逐个解析的参数不做记录。
+func RecordArguments(arguments []Argument, recorder ArgumentRecorder) []Argument {
+	out := make([]Argument, len(arguments))
+	for i, arg := range arguments {
+		out[i] = arg
+		if nil == arg.ValueResolver || (len(arg.Fields) > 0 && nil == arg.ValueLoader) {
+			continue
+		}
+		name, resolver := arg.Name, arg.ValueResolver
+		out[i].ValueResolver = func(mtv MTValue, class string, generic []string) (interface{}, error) {
+			value, err := resolver(mtv, class, generic)
+			if nil == err {
+				recorder.Record(name, value)
+			}
+			return value, err
+		}
+	}
+	return out
+}
+
 // Resolve 解析Argument参数值
 func (a Argument) Resolve(ctx Context) (interface{}, error) {
 	if nil == a.ValueResolver {
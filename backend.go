@@ -18,6 +18,20 @@ type (
 		GetResponseCodecFunc() BackendResponseCodecFunc
 	}
 
+	// BackendTransportPrewarmer 可选接口：发现BackendService时，预先初始化服务的调用资源，例如Dubbo服务引用
+	BackendTransportPrewarmer interface {
+		Prewarm(service BackendService)
+	}
+
+	// BackendTransportBatcher 可选接口：在一次通讯中执行多个BackendService，例如JSON-RPC批量请求；
+	// 聚合调用时，BatchKey相同的子服务合并为一次批量调用。
+	BackendTransportBatcher interface {
+		// BatchKey 返回服务的批量调用分组Key；返回空字符串表示不支持批量调用
+		BatchKey(service BackendService) string
+		// InvokeBatch 批量执行同一分组的多个BackendService，返回结果与服务列表按顺序一一对应
+		InvokeBatch(ctx Context, services []BackendService) ([]*BackendResponse, []*ServeError)
+	}
+
 	// BackendResponse 后端服务返回统一响应数据结构
 	BackendResponse struct {
 		// Http状态码
@@ -0,0 +1,43 @@
+package aggregate
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"sync"
+	"time"
+)
+
+var _ flux.Context = new(PartContext)
+
+// fluxContext 嵌入flux.Context，避免与Context()方法同名
+type fluxContext = flux.Context
+
+// PartContext 子服务调用的Context，使用独立的context.Context控制超时；
+// 各子服务并发执行，Metric记录需要加锁。
+type PartContext struct {
+	fluxContext
+	goctx context.Context
+	mutex *sync.Mutex
+}
+
+func NewPartContext(ctx flux.Context, goctx context.Context, mutex *sync.Mutex) *PartContext {
+	return &PartContext{
+		fluxContext: ctx,
+		goctx:       goctx,
+		mutex:       mutex,
+	}
+}
+
+func (c *PartContext) Context() context.Context {
+	return c.goctx
+}
+
+func (c *PartContext) SetContext(ctx context.Context) {
+	c.goctx = ctx
+}
+
+func (c *PartContext) AddMetric(name string, elapsed time.Duration) {
+	c.mutex.Lock()
+	c.fluxContext.AddMetric(name, elapsed)
+	c.mutex.Unlock()
+}
@@ -0,0 +1,389 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigKeyTimeout     = "timeout"
+	ConfigKeyPolicy      = "policy"
+	ConfigKeyTraceEnable = "trace_enable"
+)
+
+// Service属性：聚合服务的配置
+const (
+	// ServiceAttrTagAggregate 声明一个聚合的子服务，可声明多个。
+	// 属性值格式为字符串 "{key}:{serviceId}"，或者对象 {key, service, timeout, policy, default}
+	ServiceAttrTagAggregate = "aggregate"
+	// ServiceAttrTagAggregateTimeout 各子服务默认的调用超时时间
+	ServiceAttrTagAggregateTimeout = "aggregatetimeout"
+	// ServiceAttrTagAggregatePolicy 各子服务默认的失败处理策略
+	ServiceAttrTagAggregatePolicy = "aggregatepolicy"
+)
+
+// 子服务调用失败的处理策略
+const (
+	// PolicyFail 任意子服务失败，整个请求失败
+	PolicyFail = "fail"
+	// PolicyOmit 忽略失败的子服务，响应数据中不包含其Key
+	PolicyOmit = "omit"
+	// PolicyDefault 失败的子服务，使用其配置的默认值
+	PolicyDefault = "default"
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoAggregate, NewBackendTransportService())
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// Part 聚合的子服务定义
+	Part struct {
+		Key       string
+		ServiceId string
+		Timeout   time.Duration
+		Policy    string
+		Default   interface{}
+	}
+	// PartResult 子服务调用结果
+	PartResult struct {
+		Part     Part
+		Response *flux.BackendResponse
+		Error    *flux.ServeError
+	}
+)
+
+// BackendTransportService 聚合多个BackendService的Transport；
+// 并发调用各子服务，并将各子服务响应数据按Key合并为一个JSON对象。
+type BackendTransportService struct {
+	responseCodecFunc flux.BackendResponseCodecFunc
+	timeout           time.Duration
+	policy            string
+	traceEnable       bool
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+func NewBackendTransportService() flux.BackendTransport {
+	return NewBackendTransportServiceWith(WithResponseCodecFunc(NewBackendResponseCodecFunc()))
+}
+
+func NewBackendTransportServiceWith(opts ...Option) flux.BackendTransport {
+	bts := &BackendTransportService{
+		timeout: time.Second * 5,
+		policy:  PolicyFail,
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("Aggregate backend transport initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyTimeout:     "5s",
+		ConfigKeyPolicy:      PolicyFail,
+		ConfigKeyTraceEnable: false,
+	})
+	b.timeout = config.GetDuration(ConfigKeyTimeout)
+	b.policy = strings.ToLower(config.GetString(ConfigKeyPolicy))
+	b.traceEnable = config.GetBool(ConfigKeyTraceEnable)
+	if !isValidPolicy(b.policy) {
+		return fmt.Errorf("aggregate transport, unsupported policy: %s", b.policy)
+	}
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.responseCodecFunc(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	return result, nil
+}
+
+// Invoke 并发调用各子服务，返回按Key合并的响应数据
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	parts, err := b.ParseParts(service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageAggregateAssembleFailed,
+			Internal:   err,
+		}
+	}
+	results := make([]PartResult, len(parts))
+	wg, mutex := new(sync.WaitGroup), new(sync.Mutex)
+	for _, group := range GroupBatchParts(parts) {
+		wg.Add(1)
+		go func(group []int) {
+			defer wg.Done()
+			if len(group) == 1 {
+				results[group[0]] = b.invokePart(ctx, parts[group[0]], mutex)
+			} else {
+				b.invokeBatch(ctx, parts, group, results, mutex)
+			}
+		}(group)
+	}
+	wg.Wait()
+	out := make(map[string]interface{}, len(results))
+	for _, ret := range results {
+		if nil == ret.Error {
+			out[ret.Part.Key] = ret.Response.Body
+			continue
+		}
+		if b.traceEnable {
+			logger.WithContext(ctx).Infow("BACKEND:AGGREGATE:PART_FAILED",
+				"key", ret.Part.Key, "service-id", ret.Part.ServiceId, "policy", ret.Part.Policy, "error", ret.Error)
+		}
+		switch ret.Part.Policy {
+		case PolicyOmit:
+			continue
+		case PolicyDefault:
+			out[ret.Part.Key] = ret.Part.Default
+		default:
+			return nil, ret.Error
+		}
+	}
+	return out, nil
+}
+
+func (b *BackendTransportService) invokePart(ctx flux.Context, part Part, mutex *sync.Mutex) (ret PartResult) {
+	ret.Part = part
+	defer func() {
+		if r := recover(); nil != r {
+			ret.Error = newPanicServeError(part, r)
+		}
+	}()
+	service, ok := ext.GetBackendService(part.ServiceId)
+	if !ok {
+		ret.Error = &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageAggregateServiceNotFound,
+			Internal:   fmt.Errorf("aggregate service not found, key: %s, service-id: %s", part.Key, part.ServiceId),
+		}
+		return
+	}
+	goctx, cancel := context.WithTimeout(ctx.Context(), part.Timeout)
+	defer cancel()
+	resp, serr := backend.DoInvokeCodec(NewPartContext(ctx, goctx, mutex), service)
+	return newPartResult(part, resp, serr)
+}
+
+// invokeBatch 使用批量调用执行一组子服务；批量调用的超时时间为各子服务超时时间的最小值
+func (b *BackendTransportService) invokeBatch(ctx flux.Context, parts []Part, group []int, results []PartResult, mutex *sync.Mutex) {
+	defer func() {
+		if r := recover(); nil != r {
+			for _, i := range group {
+				results[i] = PartResult{Part: parts[i], Error: newPanicServeError(parts[i], r)}
+			}
+		}
+	}()
+	services := make([]flux.BackendService, len(group))
+	timeout := parts[group[0]].Timeout
+	for n, i := range group {
+		services[n], _ = ext.GetBackendService(parts[i].ServiceId)
+		if parts[i].Timeout < timeout {
+			timeout = parts[i].Timeout
+		}
+	}
+	batcher, _ := backendTransportOf(services[0]).(flux.BackendTransportBatcher)
+	goctx, cancel := context.WithTimeout(ctx.Context(), timeout)
+	defer cancel()
+	responses, errs := batcher.InvokeBatch(NewPartContext(ctx, goctx, mutex), services)
+	for n, i := range group {
+		results[i] = newPartResult(parts[i], responses[n], errs[n])
+	}
+}
+
+// GroupBatchParts 按批量调用分组子服务：Transport支持批量调用且BatchKey相同的子服务为一组，其它子服务各自为一组；
+// 返回各组子服务的索引列表
+func GroupBatchParts(parts []Part) [][]int {
+	groups := make([][]int, 0, len(parts))
+	batches := make(map[string]int, len(parts))
+	for i, part := range parts {
+		key := ""
+		if service, ok := ext.GetBackendService(part.ServiceId); ok {
+			if batcher, ok := backendTransportOf(service).(flux.BackendTransportBatcher); ok {
+				if bk := batcher.BatchKey(service); "" != bk {
+					key = service.AttrRpcProto() + "#" + bk
+				}
+			}
+		}
+		if "" == key {
+			groups = append(groups, []int{i})
+			continue
+		}
+		if g, ok := batches[key]; ok {
+			groups[g] = append(groups[g], i)
+		} else {
+			batches[key] = len(groups)
+			groups = append(groups, []int{i})
+		}
+	}
+	return groups
+}
+
+// newPartResult 检查子服务响应状态，并在超时取消前读取响应数据
+func newPartResult(part Part, resp *flux.BackendResponse, serr *flux.ServeError) (ret PartResult) {
+	ret.Part = part
+	if nil != serr {
+		ret.Error = serr
+		return
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		ret.Error = &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageAggregateInvokeFailed,
+			Internal:   fmt.Errorf("aggregate part failed, key: %s, status: %d", part.Key, resp.StatusCode),
+		}
+		return
+	}
+	body, err := backend.DecodeResponseBody(resp.Body)
+	if nil != err {
+		ret.Error = &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+		return
+	}
+	resp.Body = body
+	ret.Response = resp
+	return
+}
+
+func newPanicServeError(part Part, r interface{}) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		ErrorCode:  flux.ErrorCodeGatewayInternal,
+		Message:    flux.ErrorMessageAggregateInvokeFailed,
+		Internal:   fmt.Errorf("aggregate part panic, key: %s, recover: %v", part.Key, r),
+	}
+}
+
+func backendTransportOf(service flux.BackendService) flux.BackendTransport {
+	transport, _ := ext.GetBackendTransport(service.AttrRpcProto())
+	return transport
+}
+
+// ParseParts 解析服务属性中声明的子服务列表
+func (b *BackendTransportService) ParseParts(service flux.BackendService) ([]Part, error) {
+	timeout := b.timeout
+	if to := service.GetAttr(ServiceAttrTagAggregateTimeout).GetString(); "" != to {
+		d, err := time.ParseDuration(to)
+		if nil != err {
+			return nil, fmt.Errorf("illegal aggregate timeout: %s, err: %w", to, err)
+		}
+		timeout = d
+	}
+	policy := b.policy
+	if p := service.GetAttr(ServiceAttrTagAggregatePolicy).GetString(); "" != p {
+		policy = strings.ToLower(p)
+	}
+	attrs := service.GetAttrs(ServiceAttrTagAggregate)
+	if len(attrs) == 0 {
+		return nil, fmt.Errorf("aggregate parts not found, service: %s", service.ServiceID())
+	}
+	parts := make([]Part, 0, len(attrs))
+	keys := make(map[string]struct{}, len(attrs))
+	for _, attr := range attrs {
+		part := Part{Timeout: timeout, Policy: policy}
+		if text, ok := attr.Value.(string); ok {
+			kv := strings.SplitN(text, ":", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("illegal aggregate part: %s", text)
+			}
+			part.Key, part.ServiceId = strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		} else {
+			m, err := cast.ToStringMapE(attr.Value)
+			if nil != err {
+				return nil, fmt.Errorf("illegal aggregate part: %v, err: %w", attr.Value, err)
+			}
+			part.Key = cast.ToString(m["key"])
+			part.ServiceId = cast.ToString(m["service"])
+			if to := cast.ToString(m["timeout"]); "" != to {
+				d, err := time.ParseDuration(to)
+				if nil != err {
+					return nil, fmt.Errorf("illegal aggregate part timeout: %s, key: %s", to, part.Key)
+				}
+				part.Timeout = d
+			}
+			if p := cast.ToString(m["policy"]); "" != p {
+				part.Policy = strings.ToLower(p)
+			}
+			part.Default = m["default"]
+		}
+		if "" == part.Key || "" == part.ServiceId {
+			return nil, fmt.Errorf("aggregate part key and service are required, part: %v", attr.Value)
+		}
+		if _, dup := keys[part.Key]; dup {
+			return nil, fmt.Errorf("duplicated aggregate part key: %s", part.Key)
+		}
+		if !isValidPolicy(part.Policy) {
+			return nil, fmt.Errorf("unsupported aggregate policy: %s, key: %s", part.Policy, part.Key)
+		}
+		keys[part.Key] = struct{}{}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		return &flux.BackendResponse{
+			StatusCode: flux.StatusOK,
+			Headers:    make(http.Header, 0),
+			Body:       value,
+		}, nil
+	}
+}
+
+func isValidPolicy(policy string) bool {
+	switch policy {
+	case PolicyFail, PolicyOmit, PolicyDefault:
+		return true
+	default:
+		return false
+	}
+}
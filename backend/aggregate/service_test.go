@@ -0,0 +1,63 @@
+package aggregate
+
+import (
+	"github.com/bytepowered/flux"
+	_ "github.com/bytepowered/flux/backend/echo"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestBackendTransportService_Invoke(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetBackendService(flux.BackendService{
+		ServiceId: "test.aggregate.echo",
+		Interface: "test.aggregate",
+		Method:    "echo",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoEcho}},
+		},
+	})
+	newService := func(policy string) flux.BackendService {
+		return flux.BackendService{
+			Interface: "test.aggregate",
+			Method:    "page",
+			EmbeddedAttributes: flux.EmbeddedAttributes{
+				Attributes: []flux.Attribute{
+					{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoAggregate},
+					{Name: ServiceAttrTagAggregatePolicy, Value: policy},
+					{Name: ServiceAttrTagAggregate, Value: "user:test.aggregate.echo"},
+					{Name: ServiceAttrTagAggregate, Value: map[string]interface{}{
+						"key": "order", "service": "test.aggregate.missing", "default": "none",
+					}},
+				},
+			},
+		}
+	}
+	newContext := func() flux.Context {
+		return context.NewMockContext(map[string]interface{}{
+			"body": ioutil.NopCloser(strings.NewReader("")),
+		})
+	}
+	transport := NewBackendTransportService()
+	// fail
+	_, serr := transport.Invoke(newContext(), newService(PolicyFail))
+	tester.NotNil(serr)
+	tester.Equal(flux.ErrorMessageAggregateServiceNotFound, serr.Message)
+	// omit
+	ret, serr := transport.Invoke(newContext(), newService(PolicyOmit))
+	tester.Nil(serr)
+	body := ret.(map[string]interface{})
+	tester.Contains(body, "user")
+	tester.NotContains(body, "order")
+	// default
+	ret, serr = transport.Invoke(newContext(), newService(PolicyDefault))
+	tester.Nil(serr)
+	tester.Equal("none", ret.(map[string]interface{})["order"])
+	// illegal
+	_, err := transport.(*BackendTransportService).ParseParts(newService("unknown"))
+	tester.Error(err)
+}
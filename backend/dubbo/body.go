@@ -53,25 +53,7 @@ func (b BodyValues) ReadHeaderValue(headerKey string) (http.Header, error) {
 	if !ok {
 		return make(http.Header), nil
 	}
-	if mss, ok := hkv.(map[string][]string); ok {
-		return mss, nil
-	}
-	if mii, ok := hkv.(map[interface{}]interface{}); ok {
-		omap := make(http.Header)
-		for k, v := range mii {
-			_addToHeader(omap, cast.ToString(k), v)
-		}
-		return omap, nil
-	}
-	if msi, ok := hkv.(map[string]interface{}); ok {
-		omap := make(http.Header)
-		for k, v := range msi {
-			_addToHeader(omap, cast.ToString(k), v)
-		}
-		return omap, nil
-	}
-	logger.Warnw("Invalid rpc response headers", "type", reflect.TypeOf(hkv), "value", hkv)
-	return nil, ErrDecodeInvalidHeaders
+	return ParseHeaderValue(hkv)
 }
 
 func _addToHeader(headers http.Header, key string, v interface{}) {
@@ -79,6 +61,10 @@ func _addToHeader(headers http.Header, key string, v interface{}) {
 		for _, iv := range sa {
 			headers.Add(key, iv)
 		}
+	} else if ia, ok := v.([]interface{}); ok {
+		for _, iv := range ia {
+			headers.Add(key, cast.ToString(iv))
+		}
 	} else {
 		headers.Add(key, cast.ToString(v))
 	}
@@ -3,16 +3,107 @@ package dubbo
 import (
 	"github.com/apache/dubbo-go/protocol"
 	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
 	"github.com/spf13/cast"
 	"net/http"
+	"reflect"
 )
 
 const (
 	ResponseKeyStatusCode = "@net.bytepowered.flux.http-status"
 	ResponseKeyHeaders    = "@net.bytepowered.flux.http-headers"
+	ResponseKeyBody       = "@net.bytepowered.flux.http-body"
 )
 
+const (
+	// ServiceAttrTagResponseWrapped 标识服务的响应结果是否为包含Http状态码、Header和Body的包装对象
+	ServiceAttrTagResponseWrapped = "responsewrapped"
+)
+
+// ResponseMapping 定义从Dubbo响应的Attachment或包装对象中读取Http状态码、Header和Body的Key
+type ResponseMapping struct {
+	StatusKey  string
+	HeadersKey string
+	BodyKey    string
+}
+
+func DefaultResponseMapping() ResponseMapping {
+	return ResponseMapping{
+		StatusKey:  ResponseKeyStatusCode,
+		HeadersKey: ResponseKeyHeaders,
+		BodyKey:    ResponseKeyBody,
+	}
+}
+
+// NewResponseMappingOf 从配置中读取响应映射Key，未配置的使用默认值
+func NewResponseMappingOf(config *flux.Configuration) ResponseMapping {
+	mapping := DefaultResponseMapping()
+	if nil == config {
+		return mapping
+	}
+	if key := config.GetString("status"); "" != key {
+		mapping.StatusKey = key
+	}
+	if key := config.GetString("headers"); "" != key {
+		mapping.HeadersKey = key
+	}
+	if key := config.GetString("body"); "" != key {
+		mapping.BodyKey = key
+	}
+	return mapping
+}
+
+// DecodeWrapped 从包装对象中读取Http状态码、Header和Body；Body不是包装对象时，返回原响应
+func (m ResponseMapping) DecodeWrapped(resp *flux.BackendResponse) (*flux.BackendResponse, error) {
+	values, ok := toBodyValues(resp.Body)
+	if !ok {
+		return resp, nil
+	}
+	_, hasStatus := values[m.StatusKey]
+	_, hasHeaders := values[m.HeadersKey]
+	_, hasBody := values[m.BodyKey]
+	if !hasStatus && !hasHeaders && !hasBody {
+		return resp, nil
+	}
+	if hasStatus {
+		status, err := values.ReadStatusValue(m.StatusKey)
+		if nil != err {
+			return nil, err
+		}
+		resp.StatusCode = status
+	}
+	headers, err := values.ReadHeaderValue(m.HeadersKey)
+	if nil != err {
+		return nil, err
+	}
+	if nil == resp.Headers {
+		resp.Headers = make(http.Header, len(headers))
+	}
+	for k, vs := range headers {
+		for _, v := range vs {
+			resp.Headers.Add(k, v)
+		}
+	}
+	if hasBody {
+		resp.Body = values[m.BodyKey]
+	} else {
+		rest := make(map[interface{}]interface{}, len(values))
+		for k, v := range values {
+			if m.StatusKey != k && m.HeadersKey != k {
+				rest[k] = v
+			}
+		}
+		resp.Body = rest
+	}
+	return resp, nil
+}
+
 func NewBackendResponseCodecFuncWith(codeKey, headerKey string) flux.BackendResponseCodecFunc {
+	return NewBackendResponseCodecFuncOf(ResponseMapping{StatusKey: codeKey, HeadersKey: headerKey, BodyKey: ResponseKeyBody})
+}
+
+// NewBackendResponseCodecFuncOf 按响应映射Key，从Dubbo响应的Attachment中读取Http状态码和Header
+func NewBackendResponseCodecFuncOf(mapping ResponseMapping) flux.BackendResponseCodecFunc {
 	return func(ctx flux.Context, raw interface{}) (*flux.BackendResponse, error) {
 		// 支持Dubbo返回Result类型
 		rpcr, ok := raw.(protocol.Result)
@@ -27,21 +118,85 @@ func NewBackendResponseCodecFuncWith(codeKey, headerKey string) flux.BackendResp
 		}
 		data := rpcr.Result()
 		status := flux.StatusOK
+		headers := make(http.Header, 0)
 		for k, v := range rpcr.Attachments() {
-			if k == codeKey {
-				status = cast.ToInt(v)
-			} else if k == headerKey {
-				// TODO 需要更新Attachment类型为map[string]interface{}
+			if k == mapping.StatusKey {
+				code, err := cast.ToIntE(v)
+				if nil != err {
+					logger.Warnw("Invalid rpc response status", "status", v)
+					return nil, ErrDecodeInvalidStatus
+				}
+				status = code
+			} else if k == mapping.HeadersKey {
+				hv, err := ParseHeaderValue(v)
+				if nil != err {
+					return nil, err
+				}
+				headers = hv
 			} else {
 				attrs[k] = v
 			}
 		}
 		return &flux.BackendResponse{
-			StatusCode: status, Headers: make(http.Header, 0), Attachments: attrs, Body: data,
+			StatusCode: status, Headers: headers, Attachments: attrs, Body: data,
 		}, nil
 	}
 }
 
 func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
-	return NewBackendResponseCodecFuncWith(ResponseKeyStatusCode, ResponseKeyHeaders)
+	return NewBackendResponseCodecFuncOf(DefaultResponseMapping())
+}
+
+// ParseHeaderValue 解析Header值，支持Map类型，以及JSON文本格式的Map
+func ParseHeaderValue(hkv interface{}) (http.Header, error) {
+	switch v := hkv.(type) {
+	case nil:
+		return make(http.Header), nil
+	case http.Header:
+		return v, nil
+	case map[string][]string:
+		return v, nil
+	case map[interface{}]interface{}:
+		omap := make(http.Header, len(v))
+		for k, iv := range v {
+			_addToHeader(omap, cast.ToString(k), iv)
+		}
+		return omap, nil
+	case map[string]interface{}:
+		omap := make(http.Header, len(v))
+		for k, iv := range v {
+			_addToHeader(omap, k, iv)
+		}
+		return omap, nil
+	case map[string]string:
+		omap := make(http.Header, len(v))
+		for k, iv := range v {
+			omap.Add(k, iv)
+		}
+		return omap, nil
+	case string:
+		if "" == v {
+			return make(http.Header), nil
+		}
+		var msi map[string]interface{}
+		if err := _json.Unmarshal([]byte(v), &msi); nil == err {
+			return ParseHeaderValue(msi)
+		}
+	}
+	logger.Warnw("Invalid rpc response headers", "type", reflect.TypeOf(hkv), "value", hkv)
+	return nil, ErrDecodeInvalidHeaders
+}
+
+func toBodyValues(v interface{}) (BodyValues, bool) {
+	if bv, ok := WrapBodyValues(v); ok {
+		return bv, true
+	}
+	if msi, ok := v.(map[string]interface{}); ok {
+		bv := make(BodyValues, len(msi))
+		for k, iv := range msi {
+			bv[k] = iv
+		}
+		return bv, true
+	}
+	return nil, false
 }
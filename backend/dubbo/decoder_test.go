@@ -0,0 +1,51 @@
+package dubbo
+
+import (
+	"github.com/apache/dubbo-go/protocol"
+	"github.com/bytepowered/flux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestBackendResponseCodecFunc_Attachments(t *testing.T) {
+	tester := assert.New(t)
+	codec := NewBackendResponseCodecFunc()
+	resp, err := codec(nil, &protocol.RPCResult{
+		Rest: "ok",
+		Attrs: map[string]string{
+			ResponseKeyStatusCode: "302",
+			ResponseKeyHeaders:    `{"Location":"/login","Set-Cookie":["a=1","b=2"]}`,
+			"trace":               "t1",
+		},
+	})
+	tester.NoError(err)
+	tester.Equal(http.StatusFound, resp.StatusCode)
+	tester.Equal("/login", resp.Headers.Get("Location"))
+	tester.Equal([]string{"a=1", "b=2"}, resp.Headers.Values("Set-Cookie"))
+	tester.Equal(map[string]interface{}{"trace": "t1"}, resp.Attachments)
+	tester.Equal("ok", resp.Body)
+	_, err = codec(nil, &protocol.RPCResult{Attrs: map[string]string{ResponseKeyHeaders: "no-headers"}})
+	tester.Equal(ErrDecodeInvalidHeaders, err)
+}
+
+func TestResponseMapping_DecodeWrapped(t *testing.T) {
+	tester := assert.New(t)
+	mapping := ResponseMapping{StatusKey: "status", HeadersKey: "headers", BodyKey: "body"}
+	resp, err := mapping.DecodeWrapped(&flux.BackendResponse{
+		StatusCode: flux.StatusOK,
+		Body: map[interface{}]interface{}{
+			"status":  201,
+			"headers": map[interface{}]interface{}{"Cache-Control": "no-cache"},
+			"body":    map[interface{}]interface{}{"id": 1},
+		},
+	})
+	tester.NoError(err)
+	tester.Equal(http.StatusCreated, resp.StatusCode)
+	tester.Equal("no-cache", resp.Headers.Get("Cache-Control"))
+	tester.Equal(map[interface{}]interface{}{"id": 1}, resp.Body)
+	// 非包装对象，保持原响应
+	resp, err = mapping.DecodeWrapped(&flux.BackendResponse{StatusCode: flux.StatusOK, Body: "text"})
+	tester.NoError(err)
+	tester.Equal("text", resp.Body)
+}
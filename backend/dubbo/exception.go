@@ -0,0 +1,178 @@
+package dubbo
+
+import (
+	"errors"
+	"fmt"
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// ConfigKeyExceptionMappings Java异常到网关错误的映射规则列表
+	ConfigKeyExceptionMappings = "exception_mappings"
+)
+
+var (
+	registeredExceptions = new(sync.Map)
+)
+
+// RegisterJavaException 注册Java异常类对应的Go结构体，用于Hessian解码。
+// 未注册的异常类被解码为 hessian.UnknownException，只保留异常消息和堆栈，且丢失异常类名，无法按类名匹配映射规则；
+// 映射规则需要匹配自定义异常类或读取其错误码字段（code_field）时，必须在首次调用之前注册异常类型，例如：
+//
+//	type BizException struct {
+//		java_exception.Exception
+//		Code string
+//	}
+//	func (BizException) JavaClassName() string { return "com.example.BizException" }
+//	dubbo.RegisterJavaException(&BizException{})
+func RegisterJavaException(exception java_exception.Throwabler) {
+	hessian.RegisterPOJO(exception)
+	registeredExceptions.Store(exception.JavaClassName(), true)
+}
+
+func isRegisteredException(className string) bool {
+	registered := false
+	registeredExceptions.Range(func(key, _ interface{}) bool {
+		name := key.(string)
+		registered = name == className || strings.HasSuffix(name, "."+className)
+		return !registered
+	})
+	return registered
+}
+
+// ExceptionMapping 定义Java异常到网关错误的映射规则
+type ExceptionMapping struct {
+	Exception     string         // Java异常类名，支持全限定类名或简单类名
+	MessageRegex  *regexp.Regexp // 匹配异常消息的正则表达式，可选
+	StatusCode    int            // 响应状态码
+	ErrorCode     interface{}    // 错误码
+	Message       string         // 返回请求端的错误消息
+	ExposeMessage bool           // 是否使用异常消息作为返回请求端的错误消息
+	CodeField     string         // 从异常对象中读取错误码的字段名，可选；读取成功时替代ErrorCode
+}
+
+// ExceptionMappings 按声明顺序匹配的映射规则列表
+type ExceptionMappings []ExceptionMapping
+
+// NewExceptionMappingsOf 从配置中读取Java异常映射规则
+func NewExceptionMappingsOf(config *flux.Configuration) (ExceptionMappings, error) {
+	items := cast.ToSlice(config.Get(ConfigKeyExceptionMappings))
+	mappings := make(ExceptionMappings, 0, len(items))
+	for _, item := range items {
+		values := cast.ToStringMap(item)
+		mapping := ExceptionMapping{
+			Exception:     cast.ToString(values["exception"]),
+			StatusCode:    cast.ToInt(values["status"]),
+			ErrorCode:     values["error_code"],
+			Message:       cast.ToString(values["message"]),
+			ExposeMessage: cast.ToBool(values["expose_message"]),
+			CodeField:     cast.ToString(values["code_field"]),
+		}
+		if "" == mapping.Exception {
+			return nil, fmt.Errorf("dubbo exception mapping requires exception class, mapping: %+v", values)
+		}
+		if pattern := cast.ToString(values["message_pattern"]); "" != pattern {
+			regex, err := regexp.Compile(pattern)
+			if nil != err {
+				return nil, fmt.Errorf("dubbo exception mapping, illegal message pattern: %s, err: %w", pattern, err)
+			}
+			mapping.MessageRegex = regex
+		}
+		if "" != mapping.CodeField && !isRegisteredException(mapping.Exception) {
+			logger.Warnw("Dubbo exception mapping reads code field, but exception class is not registered",
+				"exception", mapping.Exception, "code-field", mapping.CodeField)
+		}
+		if 0 == mapping.StatusCode {
+			mapping.StatusCode = flux.StatusBadGateway
+		}
+		if nil == mapping.ErrorCode {
+			mapping.ErrorCode = flux.ErrorCodeGatewayBackend
+		}
+		if "" == mapping.Message {
+			mapping.Message = flux.ErrorMessageDubboInvokeFailed
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// Match 判断异常类名和异常消息是否匹配规则
+func (m ExceptionMapping) Match(className, message string) bool {
+	if className != m.Exception && !strings.HasSuffix(className, "."+m.Exception) {
+		return false
+	}
+	return nil == m.MessageRegex || m.MessageRegex.MatchString(message)
+}
+
+// Resolve 将Dubbo调用错误转换为映射规则声明的网关错误；没有匹配的规则时，返回false
+func (ms ExceptionMappings) Resolve(err error) (*flux.ServeError, bool) {
+	if len(ms) == 0 || nil == err {
+		return nil, false
+	}
+	var throwable java_exception.Throwabler
+	if !errors.As(err, &throwable) {
+		return nil, false
+	}
+	className, message := throwable.JavaClassName(), exceptionMessage(throwable)
+	for _, m := range ms {
+		if !m.Match(className, message) {
+			continue
+		}
+		serr := &flux.ServeError{
+			StatusCode: m.StatusCode,
+			ErrorCode:  m.ErrorCode,
+			Message:    m.Message,
+			Internal:   err,
+		}
+		if m.ExposeMessage && "" != message {
+			serr.Message = message
+		}
+		if "" != m.CodeField {
+			if code, ok := lookupExceptionField(throwable, m.CodeField); ok {
+				serr.ErrorCode = code
+			}
+		}
+		return serr, true
+	}
+	return nil, false
+}
+
+// exceptionMessage 返回异常消息（DetailMessage）；hessian.UnknownException 的Error()包含异常类名前缀，不能直接使用
+func exceptionMessage(throwable java_exception.Throwabler) string {
+	v := reflect.Indirect(reflect.ValueOf(throwable))
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("DetailMessage"); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+	}
+	return throwable.Error()
+}
+
+// lookupExceptionField 读取异常对象的字段值，字段名不区分大小写
+func lookupExceptionField(throwable java_exception.Throwabler, field string) (interface{}, bool) {
+	v := reflect.Indirect(reflect.ValueOf(throwable))
+	switch v.Kind() {
+	case reflect.Struct:
+		f := v.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, field)
+		})
+		if f.IsValid() && f.CanInterface() && !f.IsZero() {
+			return f.Interface(), true
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if strings.EqualFold(cast.ToString(key.Interface()), field) {
+				return v.MapIndex(key).Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
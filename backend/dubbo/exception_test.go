@@ -0,0 +1,129 @@
+package dubbo
+
+import (
+	"bytes"
+	"errors"
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/bytepowered/flux"
+	perrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+type testBizException struct {
+	java_exception.Exception
+	Code string
+}
+
+func (testBizException) JavaClassName() string {
+	return "net.bytepowered.test.BizException"
+}
+
+func TestExceptionMappings_Resolve(t *testing.T) {
+	tester := assert.New(t)
+	mappings, err := NewExceptionMappingsOf(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyExceptionMappings: []interface{}{
+			map[string]interface{}{
+				"exception":      "java.lang.IllegalArgumentException",
+				"status":         400,
+				"error_code":     flux.ErrorCodeRequestInvalid,
+				"expose_message": true,
+			},
+			map[string]interface{}{
+				"exception":  "BizException",
+				"status":     422,
+				"error_code": "BIZ:ERROR",
+				"message":    "BIZ:FAILED",
+				"code_field": "code",
+			},
+		},
+	}))
+	tester.NoError(err)
+	// 经过Dubbo集群包装的异常
+	serr, ok := mappings.Resolve(perrors.Wrap(java_exception.NewIllegalArgumentException("id is required"), "failed to invoke"))
+	tester.True(ok)
+	tester.Equal(http.StatusBadRequest, serr.StatusCode)
+	tester.Equal(flux.ErrorCodeRequestInvalid, serr.ErrorCode)
+	tester.Equal("id is required", serr.Message)
+	// 自定义异常，读取错误码字段
+	biz := &testBizException{Exception: *java_exception.NewException("balance not enough"), Code: "E1001"}
+	serr, ok = mappings.Resolve(biz)
+	tester.True(ok)
+	tester.Equal(http.StatusUnprocessableEntity, serr.StatusCode)
+	tester.Equal("E1001", serr.ErrorCode)
+	tester.Equal("BIZ:FAILED", serr.Message)
+	// 未映射的异常
+	_, ok = mappings.Resolve(java_exception.NewIllegalStateException("state"))
+	tester.False(ok)
+	_, ok = mappings.Resolve(errors.New("connection refused"))
+	tester.False(ok)
+}
+
+// testRemoteException 模拟服务端的异常对象，编码后替换类名，作为网关未注册的异常类
+type testRemoteException struct {
+	SerialVersionUID     int64
+	DetailMessage        string
+	SuppressedExceptions []java_exception.Throwabler
+	StackTrace           []java_exception.StackTraceElement
+	Cause                java_exception.Throwabler
+	Code                 string
+}
+
+func (testRemoteException) JavaClassName() string {
+	return "net.bytepowered.test.XRemoteException"
+}
+
+type testRegisteredException struct {
+	java_exception.Exception
+	Code string
+}
+
+func (testRegisteredException) JavaClassName() string {
+	return "net.bytepowered.test.YRemoteException"
+}
+
+func TestExceptionMappings_ResolveUnknownException(t *testing.T) {
+	tester := assert.New(t)
+	encoder := hessian.NewEncoder()
+	tester.NoError(encoder.Encode(&testRemoteException{
+		DetailMessage: "balance not enough",
+		StackTrace:    []java_exception.StackTraceElement{},
+		Code:          "E1001",
+	}))
+	decode := func(className string) error {
+		data := bytes.Replace(encoder.Buffer(), []byte("XRemoteException"), []byte(className), 1)
+		v, err := hessian.NewDecoder(data).Decode()
+		tester.NoError(err)
+		return v.(error)
+	}
+	newMappings := func(exception string) ExceptionMappings {
+		mappings, err := NewExceptionMappingsOf(flux.NewConfigurationOfMap(map[string]interface{}{
+			ConfigKeyExceptionMappings: []interface{}{
+				map[string]interface{}{
+					"exception":      exception,
+					"status":         422,
+					"expose_message": true,
+					"code_field":     "code",
+				},
+			},
+		}))
+		tester.NoError(err)
+		return mappings
+	}
+	// 未注册的异常类：解码为UnknownException，丢失类名和错误码字段，异常消息不包含类名前缀
+	unknown := decode("ZRemoteException")
+	_, ok := unknown.(*hessian.UnknownException)
+	tester.True(ok)
+	tester.Equal("balance not enough", exceptionMessage(unknown.(java_exception.Throwabler)))
+	_, ok = newMappings("ZRemoteException").Resolve(unknown)
+	tester.False(ok)
+	// 注册的异常类：按类名匹配，读取错误码字段
+	RegisterJavaException(&testRegisteredException{})
+	serr, ok := newMappings("YRemoteException").Resolve(decode("YRemoteException"))
+	tester.True(ok)
+	tester.Equal(http.StatusUnprocessableEntity, serr.StatusCode)
+	tester.Equal("E1001", serr.ErrorCode)
+	tester.Equal("balance not enough", serr.Message)
+}
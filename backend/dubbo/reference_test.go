@@ -0,0 +1,71 @@
+package dubbo
+
+import (
+	"fmt"
+	_ "github.com/apache/dubbo-go/cluster/cluster_impl"
+	_ "github.com/apache/dubbo-go/cluster/loadbalance"
+	_ "github.com/apache/dubbo-go/common/proxy/proxy_factory"
+	dubgo "github.com/apache/dubbo-go/config"
+	_ "github.com/apache/dubbo-go/filter/filter_impl"
+	"github.com/apache/dubbo-go/protocol/dubbo"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewReferenceKey(t *testing.T) {
+	tester := assert.New(t)
+	newService := func(group, version string) *flux.BackendService {
+		return &flux.BackendService{
+			Interface:  "net.bytepowered.flux.TestService",
+			RemoteHost: "dubbo://127.0.0.1:20880",
+			EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcGroup, Value: group},
+				{Name: flux.ServiceAttrTagRpcVersion, Value: version},
+			}},
+		}
+	}
+	tester.Equal("a/net.bytepowered.flux.TestService:1.0@dubbo://127.0.0.1:20880", NewReferenceKey(newService("a", "1.0")))
+	tester.NotEqual(NewReferenceKey(newService("a", "1.0")), NewReferenceKey(newService("b", "1.0")))
+	tester.NotEqual(NewReferenceKey(newService("a", "1.0")), NewReferenceKey(newService("a", "2.0")))
+}
+
+func TestBackendTransportService_PrewarmConcurrently(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	consumer := dubgo.ConsumerConfig{}
+	consumer.ApplicationConfig = &dubgo.ApplicationConfig{Name: "flux-test"}
+	dubgo.SetConsumerConfig(consumer)
+	dubbo.SetClientConf(dubbo.GetDefaultClientConfig())
+	transport := NewBackendTransportService().(*BackendTransportService)
+	tester.NoError(transport.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyReferenceDelay: "1ms",
+	})))
+	services := make([]flux.BackendService, 0, 8)
+	for _, group := range []string{"a", "b"} {
+		for _, version := range []string{"1.0", "2.0", "3.0", "4.0"} {
+			services = append(services, flux.BackendService{
+				Interface:  "net.bytepowered.flux.TestService",
+				RemoteHost: "dubbo://127.0.0.1:1",
+				EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+					{Name: flux.ServiceAttrTagRpcGroup, Value: group},
+					{Name: flux.ServiceAttrTagRpcVersion, Value: version},
+				}},
+			})
+		}
+	}
+	// 同一Interface的不同Group/Version并发创建引用
+	for _, service := range services {
+		transport.Prewarm(service)
+	}
+	loaded := make(map[string]bool, len(services))
+	for i := range services {
+		srv := transport.LoadGenericService(&services[i])
+		tester.NotNil(srv)
+		loaded[fmt.Sprintf("%p", srv)] = true
+		tester.Equal(srv, transport.LoadGenericService(&services[i]))
+	}
+	tester.Equal(len(services), len(loaded))
+}
@@ -28,8 +28,10 @@ import (
 )
 
 const (
-	ConfigKeyTraceEnable    = "trace_enable"
-	ConfigKeyReferenceDelay = "reference_delay"
+	ConfigKeyTraceEnable      = "trace_enable"
+	ConfigKeyReferenceDelay   = "reference_delay"
+	ConfigKeyResponseMapping  = "response_mapping"
+	ConfigKeyReferencePrewarm = "reference_prewarm"
 )
 
 func init() {
@@ -46,6 +48,11 @@ var (
 	_json                       = jsoniter.ConfigCompatibleWithStandardLibrary
 )
 
+var (
+	// referMutex Dubbo-go创建引用时读写其全局状态，没有并发保护；不同引用的创建需要串行执行
+	referMutex sync.Mutex
+)
+
 type (
 	// Option func to set option
 	Option func(service *BackendTransportService)
@@ -78,10 +85,18 @@ type BackendTransportService struct {
 	argAssembleFunc   ArgumentsAssembleFunc         // Dubbo参数封装函数
 	attAssembleFunc   AttachmentAssembleFun         // Attachment封装函数
 	responseCodecFunc flux.BackendResponseCodecFunc // 解析响应结果的函数
+	responseMapping   ResponseMapping               // 响应结果中Http状态码、Header和Body的映射Key
+	exceptionMappings ExceptionMappings             // Java异常到网关错误的映射规则
 	// 内部私有
 	traceEnable   bool
 	configuration *flux.Configuration
-	serviceMutex  sync.RWMutex
+	references    sync.Map // ReferenceKey -> *genericReference
+}
+
+// genericReference 缓存的Dubbo泛化调用服务；ready关闭时表示创建完成
+type genericReference struct {
+	ready   chan struct{}
+	service common.RPCService
 }
 
 // WithArgumentAssembleFunc 用于配置Dubbo参数封装实现函数
@@ -105,6 +120,13 @@ func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
 	}
 }
 
+// WithResponseMapping 用于配置响应结果中Http状态码、Header和Body的映射Key
+func WithResponseMapping(mapping ResponseMapping) Option {
+	return func(service *BackendTransportService) {
+		service.responseMapping = mapping
+	}
+}
+
 // WithGenericOptionsFunc 用于配置DubboReference的参数配置函数
 func WithGenericOptionsFunc(fun DubboGenericOptionsFunc) Option {
 	return func(service *BackendTransportService) {
@@ -144,6 +166,7 @@ func WithDefaults(defaults map[string]interface{}) Option {
 func NewBackendTransportServiceWith(opts ...Option) flux.BackendTransport {
 	bts := &BackendTransportService{
 		dubboOptionsFunc: make([]DubboGenericOptionsFunc, 0),
+		responseMapping:  DefaultResponseMapping(),
 	}
 	for _, opt := range opts {
 		opt(bts)
@@ -161,7 +184,6 @@ func NewBackendTransportServiceOverrides(overrides ...Option) flux.BackendTransp
 	opts := []Option{
 		WithArgumentAssembleFunc(DefaultArgAssembleFunc),
 		WithAttachmentAssembleFunc(DefaultAttAssembleFun),
-		WithResponseCodecFunc(NewBackendResponseCodecFunc()),
 		WithRegistryAlias(map[string]string{
 			"id":       "dubbo.registry.id",
 			"protocol": "dubbo.registry.protocol",
@@ -172,13 +194,14 @@ func NewBackendTransportServiceOverrides(overrides ...Option) flux.BackendTransp
 			"password": "dubbo.registry.password",
 		}),
 		WithDefaults(map[string]interface{}{
-			ConfigKeyReferenceDelay: time.Millisecond * 10,
-			ConfigKeyTraceEnable:    false,
-			"timeout":               "5000",
-			"retries":               "0",
-			"cluster":               "failover",
-			"load_balance":          "random",
-			"protocol":              dubbo.DUBBO,
+			ConfigKeyReferenceDelay:   time.Millisecond * 10,
+			ConfigKeyTraceEnable:      false,
+			ConfigKeyReferencePrewarm: true,
+			"timeout":                 "5000",
+			"retries":                 "0",
+			"cluster":                 "failover",
+			"load_balance":            "random",
+			"protocol":                dubbo.DUBBO,
 		}),
 		WithGenericServiceFunc(func(backend *flux.BackendService) common.RPCService {
 			return dubgo.NewGenericService(backend.Interface)
@@ -220,6 +243,20 @@ func (b *BackendTransportService) Init(config *flux.Configuration) error {
 	if pkg.IsNil(b.argAssembleFunc) {
 		b.argAssembleFunc = DefaultArgAssembleFunc
 	}
+	// 响应映射Key：配置优先
+	if config.IsSet(ConfigKeyResponseMapping) {
+		b.responseMapping = NewResponseMappingOf(config.Sub(ConfigKeyResponseMapping))
+	}
+	if pkg.IsNil(b.responseCodecFunc) {
+		b.responseCodecFunc = NewBackendResponseCodecFuncOf(b.responseMapping)
+	}
+	// Java异常映射规则
+	if mappings, err := NewExceptionMappingsOf(config); nil != err {
+		return err
+	} else {
+		b.exceptionMappings = mappings
+		logger.Infow("Dubbo backend transport exception mappings", "size", len(mappings))
+	}
 	// 修改默认Consumer配置
 	consumerc := dubgo.GetConsumerConfig()
 	// 支持定义Registry
@@ -279,6 +316,17 @@ func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.Bac
 			Internal:   fmt.Errorf("decode dubbo response, err: %w", err),
 		}
 	}
+	// 服务声明响应结果为包装对象时，从包装对象中读取Http状态码、Header和Body
+	if service.GetAttr(ServiceAttrTagResponseWrapped).GetBool() {
+		if result, err = b.responseMapping.DecodeWrapped(result); nil != err {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessageBackendDecodeResponse,
+				Internal:   fmt.Errorf("decode dubbo wrapped response, err: %w", err),
+			}
+		}
+	}
 	return result, nil
 }
 
@@ -301,10 +349,13 @@ func (b *BackendTransportService) DoInvoke(types []string, values interface{}, s
 	}
 	goctx := context.WithValue(ctx.Context(), constant.AttachmentKey, att)
 	generic := b.LoadGenericService(&service)
-	resultW := b.dubboInvokeFunc(goctx, []interface{}{service.Method, types, values}, generic)
+	resultW := b.invokeWithDeadline(goctx, []interface{}{service.Method, types, values}, generic)
 	if err := resultW.Error(); err != nil {
 		logger.WithContext(ctx).Errorw("BACKEND:DUBBO:RPC_ERROR",
 			"backend-service", service.ServiceID(), "error", err)
+		if serr, ok := b.exceptionMappings.Resolve(err); ok {
+			return nil, serr
+		}
 		return nil, &flux.ServeError{
 			StatusCode: flux.StatusBadGateway,
 			ErrorCode:  flux.ErrorCodeGatewayBackend,
@@ -327,13 +378,69 @@ func (b *BackendTransportService) DoInvoke(types []string, values interface{}, s
 	}
 }
 
-// LoadGenericService create and cache dubbo generic service
+// invokeWithDeadline 执行Dubbo泛调用；请求截止时间到达时直接返回，不等待Dubbo调用完成
+func (b *BackendTransportService) invokeWithDeadline(goctx context.Context, args []interface{}, rpc common.RPCService) protocol.Result {
+	if _, ok := goctx.Deadline(); !ok {
+		return b.dubboInvokeFunc(goctx, args, rpc)
+	}
+	done := make(chan protocol.Result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); nil != r {
+				done <- &protocol.RPCResult{Err: fmt.Errorf("dubbo invoke panic, recover: %v", r)}
+			}
+		}()
+		done <- b.dubboInvokeFunc(goctx, args, rpc)
+	}()
+	select {
+	case ret := <-done:
+		return ret
+	case <-goctx.Done():
+		return &protocol.RPCResult{Err: goctx.Err()}
+	}
+}
+
+// LoadGenericService 按服务的Interface、Group、Version和URL加载Dubbo泛化调用服务；
+// 相同服务的并发请求只创建一次引用，并等待创建完成；不同服务的引用创建互不阻塞。
 func (b *BackendTransportService) LoadGenericService(backend *flux.BackendService) common.RPCService {
-	b.serviceMutex.Lock()
-	defer b.serviceMutex.Unlock()
-	if srv := dubgo.GetConsumerService(backend.Interface); nil != srv {
-		return srv
+	key := NewReferenceKey(backend)
+	ref := &genericReference{ready: make(chan struct{})}
+	if actual, loaded := b.references.LoadOrStore(key, ref); loaded {
+		cached := actual.(*genericReference)
+		<-cached.ready
+		if nil != cached.service {
+			return cached.service
+		}
+		// 创建失败的引用已被移除，重新创建
+		return b.LoadGenericService(backend)
 	}
+	defer func() {
+		if nil == ref.service {
+			b.references.Delete(key)
+		}
+		close(ref.ready)
+	}()
+	ref.service = b.newGenericService(key, backend)
+	return ref.service
+}
+
+// Prewarm 发现Dubbo服务时，预先创建泛化调用服务的引用
+func (b *BackendTransportService) Prewarm(service flux.BackendService) {
+	if nil == b.configuration || !b.configuration.GetBool(ConfigKeyReferencePrewarm) || "" == service.Interface {
+		return
+	}
+	go func() {
+		defer func() {
+			if r := recover(); nil != r {
+				logger.Warnw("Prewarm dubbo generic service, recover", "service", NewReferenceKey(&service), "recover", r)
+			}
+		}()
+		b.LoadGenericService(&service)
+	}()
+}
+
+func (b *BackendTransportService) newGenericService(key string, backend *flux.BackendService) common.RPCService {
+	// Reference的ID作为Dubbo调用的服务路径，必须为Interface；引用缓存以ReferenceKey区分
 	newRef := NewReference(backend.Interface, backend, b.configuration)
 	// Options
 	const msg = "Dubbo option-func return nil reference"
@@ -342,20 +449,32 @@ func (b *BackendTransportService) LoadGenericService(backend *flux.BackendServic
 			newRef = pkg.RequireNotNil(optsFunc(backend, b.configuration, newRef), msg).(*dubgo.ReferenceConfig)
 		}
 	}
-	logger.Infow("Create dubbo generic service: PENDING", "interface", backend.Interface)
+	logger.Infow("Create dubbo generic service: PENDING", "reference", key)
 	srv := b.dubboServiceFunc(backend)
-	dubgo.SetConsumerService(srv)
-	newRef.Refer(srv)
-	newRef.Implement(srv)
+	// 泛化调用不需要注册ConsumerService：其按Interface索引，会被同一Interface的不同Group/Version覆盖
+	referGenericService(newRef, srv)
 	t := b.configuration.GetDuration(ConfigKeyReferenceDelay)
 	if t == 0 {
 		t = time.Millisecond * 10
 	}
 	<-time.After(t)
-	logger.Infow("Create dubbo generic service: OK", "interface", backend.Interface)
+	logger.Infow("Create dubbo generic service: OK", "reference", key)
 	return srv
 }
 
+func referGenericService(ref *dubgo.ReferenceConfig, srv common.RPCService) {
+	referMutex.Lock()
+	defer referMutex.Unlock()
+	ref.Refer(srv)
+	ref.Implement(srv)
+}
+
+// NewReferenceKey 返回Dubbo服务引用的唯一标识：{group}/{interface}:{version}@{url}
+func NewReferenceKey(backend *flux.BackendService) string {
+	return fmt.Sprintf("%s/%s:%s@%s",
+		backend.AttrRpcGroup(), backend.Interface, backend.AttrRpcVersion(), backend.RemoteHost)
+}
+
 func newConsumerRegistry(config *flux.Configuration) (string, *dubgo.RegistryConfig) {
 	if !config.IsSet("id", "protocol") {
 		return "", nil
@@ -386,7 +505,8 @@ func NewReference(refid string, service *flux.BackendService, config *flux.Confi
 	ref.Version = service.AttrRpcVersion()
 	ref.Group = service.AttrRpcGroup()
 	ref.RequestTimeout = service.AttrRpcTimeout()
-	ref.Retries = service.AttrRpcRetries()
+	// 重试由网关的重试策略执行（rpcretries，且声明 idempotent=true），避免与Dubbo集群重试叠加
+	ref.Retries = "0"
 	ref.Cluster = config.GetString("cluster")
 	ref.Protocol = config.GetString("protocol")
 	ref.Loadbalance = config.GetString("load_balance")
@@ -0,0 +1,360 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/spf13/cast"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// ConfigKeyResponseEnvelopes 响应信封的全局配置
+	ConfigKeyResponseEnvelopes = "response_envelopes"
+	// ConfigKeyEnvelopeDefault 未声明envelope属性的服务，默认使用的信封规则名称；为空时不处理
+	ConfigKeyEnvelopeDefault = "default"
+	// ConfigKeyEnvelopeProfiles 按名称声明的信封规则
+	ConfigKeyEnvelopeProfiles = "profiles"
+)
+
+const (
+	// ServiceAttrTagEnvelope 声明服务响应使用的信封规则名称；none 表示不处理
+	ServiceAttrTagEnvelope = "envelope"
+	// EnvelopeNone 不处理响应信封
+	EnvelopeNone = "none"
+)
+
+var (
+	responseEnvelopes    = new(sync.Map)
+	defaultEnvelopeName  = ""
+	defaultEnvelopeMutex = new(sync.RWMutex)
+)
+
+type (
+	// EnvelopeCodeMapping 业务码到Http状态码和网关错误的映射
+	EnvelopeCodeMapping struct {
+		Code       string      // 业务码
+		StatusCode int         // 响应状态码；小于400时，按成功响应处理
+		ErrorCode  interface{} // 错误码；为空时使用业务码
+		Message    string      // 返回请求端的错误消息；为空时使用业务消息
+	}
+	// ResponseEnvelope 识别形如 {code, msg, data} 的业务响应信封：成功时解出data，失败时按映射表转换为ServeError
+	ResponseEnvelope struct {
+		Name         string
+		CodeField    string
+		MessageField string
+		DataField    string
+		SuccessCodes []string
+		// 映射表中没有声明的失败业务码，使用的响应状态码
+		ErrorStatus int
+		Mappings    map[string]EnvelopeCodeMapping
+		// 是否将成功响应的data重新封装为网关标准信封：{status, data}
+		Rewrap bool
+		// 识别信封的最大响应数据长度，超过时不做处理；小于等于0时不限制
+		MaxBodySize int64
+	}
+)
+
+// NewResponseEnvelopeOf 从配置中读取信封规则
+func NewResponseEnvelopeOf(name string, config *flux.Configuration) (*ResponseEnvelope, error) {
+	config.SetDefaults(map[string]interface{}{
+		"code_field":    "code",
+		"message_field": "msg",
+		"data_field":    "data",
+		"success_codes": []string{"0"},
+		"error_status":  flux.StatusBadGateway,
+		"rewrap":        false,
+		"max_body_size": 1 << 20,
+	})
+	envelope := &ResponseEnvelope{
+		Name:         name,
+		CodeField:    config.GetString("code_field"),
+		MessageField: config.GetString("message_field"),
+		DataField:    config.GetString("data_field"),
+		SuccessCodes: config.GetStringSlice("success_codes"),
+		ErrorStatus:  config.GetInt("error_status"),
+		Mappings:     make(map[string]EnvelopeCodeMapping),
+		Rewrap:       config.GetBool("rewrap"),
+		MaxBodySize:  config.GetInt64("max_body_size"),
+	}
+	if "" == envelope.CodeField {
+		return nil, fmt.Errorf("response envelope requires code field, envelope: %s", name)
+	}
+	for _, item := range cast.ToSlice(config.Get("mappings")) {
+		values := cast.ToStringMap(item)
+		mapping := EnvelopeCodeMapping{
+			Code:       cast.ToString(values["code"]),
+			StatusCode: cast.ToInt(values["status"]),
+			ErrorCode:  values["error_code"],
+			Message:    cast.ToString(values["message"]),
+		}
+		if "" == mapping.Code {
+			return nil, fmt.Errorf("response envelope mapping requires code, envelope: %s, mapping: %+v", name, values)
+		}
+		if 0 == mapping.StatusCode {
+			mapping.StatusCode = envelope.ErrorStatus
+		}
+		envelope.Mappings[mapping.Code] = mapping
+	}
+	return envelope, nil
+}
+
+// InitResponseEnvelopes 加载全局配置的信封规则
+func InitResponseEnvelopes(config *flux.Configuration) error {
+	for name := range config.GetStringMap(ConfigKeyEnvelopeProfiles) {
+		envelope, err := NewResponseEnvelopeOf(name, config.Sub(ConfigKeyEnvelopeProfiles+"."+name))
+		if nil != err {
+			return err
+		}
+		SetResponseEnvelope(envelope)
+	}
+	name := config.GetString(ConfigKeyEnvelopeDefault)
+	if _, ok := GetResponseEnvelope(name); "" != name && !ok {
+		return fmt.Errorf("default response envelope not found: %s", name)
+	}
+	SetDefaultResponseEnvelope(name)
+	return nil
+}
+
+// SetResponseEnvelope 注册信封规则
+func SetResponseEnvelope(envelope *ResponseEnvelope) {
+	responseEnvelopes.Store(envelope.Name, envelope)
+}
+
+// GetResponseEnvelope 获取指定名称的信封规则
+func GetResponseEnvelope(name string) (*ResponseEnvelope, bool) {
+	v, ok := responseEnvelopes.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*ResponseEnvelope), true
+}
+
+// SetDefaultResponseEnvelope 设置未声明envelope属性的服务默认使用的信封规则名称
+func SetDefaultResponseEnvelope(name string) {
+	defaultEnvelopeMutex.Lock()
+	defaultEnvelopeName = name
+	defaultEnvelopeMutex.Unlock()
+}
+
+// LookupResponseEnvelope 查找服务使用的信封规则；聚合和链式调用服务由子服务各自处理信封，不使用信封规则
+func LookupResponseEnvelope(service flux.BackendService) (*ResponseEnvelope, bool) {
+	if IsCompositeService(service) {
+		return nil, false
+	}
+	name := service.GetAttr(ServiceAttrTagEnvelope).GetString()
+	if "" == name {
+		defaultEnvelopeMutex.RLock()
+		name = defaultEnvelopeName
+		defaultEnvelopeMutex.RUnlock()
+	}
+	if "" == name || EnvelopeNone == name {
+		return nil, false
+	}
+	return GetResponseEnvelope(name)
+}
+
+// DecodeResponseEnvelope 按服务使用的信封规则处理响应数据；服务没有使用信封规则时，返回原响应
+func DecodeResponseEnvelope(service flux.BackendService, resp *flux.BackendResponse) (*flux.BackendResponse, *flux.ServeError) {
+	envelope, ok := LookupResponseEnvelope(service)
+	if !ok {
+		return resp, nil
+	}
+	return envelope.Decode(resp)
+}
+
+// Decode 识别响应信封：成功业务码解出data；失败业务码按映射表转换为ServeError。
+// 非成功的Http响应、非JSON的响应数据和流式响应，不做处理。
+func (e *ResponseEnvelope) Decode(resp *flux.BackendResponse) (*flux.BackendResponse, *flux.ServeError) {
+	if nil == resp || resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp, nil
+	}
+	if _, ok := resp.Body.(flux.WebServePayload); ok {
+		return resp, nil
+	}
+	if nil != resp.Headers {
+		if ct := resp.Headers.Get(flux.HeaderContentType); "" != ct && !strings.Contains(ct, "json") {
+			return resp, nil
+		}
+	}
+	fields, ok, err := e.readFields(resp)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	if !ok {
+		return resp, nil
+	}
+	code, message := cast.ToString(fields.code), fields.message
+	if e.isSuccess(code) {
+		return e.unwrap(resp, fields, flux.StatusOK), nil
+	}
+	if mapping, ok := e.Mappings[code]; ok && mapping.StatusCode < http.StatusBadRequest {
+		return e.unwrap(resp, fields, mapping.StatusCode), nil
+	}
+	serr := &flux.ServeError{
+		StatusCode: e.ErrorStatus,
+		ErrorCode:  fields.code,
+		Message:    message,
+		Internal:   fmt.Errorf("business error, envelope: %s, code: %s, message: %s", e.Name, code, message),
+	}
+	if mapping, ok := e.Mappings[code]; ok {
+		serr.StatusCode = mapping.StatusCode
+		if nil != mapping.ErrorCode {
+			serr.ErrorCode = mapping.ErrorCode
+		}
+		if "" != mapping.Message {
+			serr.Message = mapping.Message
+		}
+	}
+	if "" == serr.Message {
+		serr.Message = flux.ErrorMessageEnvelopeBusinessError
+	}
+	return nil, serr
+}
+
+// envelopeFields 响应信封的字段；JSON响应数据的data字段保留原始数据，避免重新序列化时丢失大整数精度
+type envelopeFields struct {
+	code    interface{}
+	message string
+	data    interface{}
+	rawData json.RawMessage
+	raw     bool
+}
+
+// readFields 读取响应数据中的信封字段；响应数据不是包含业务码字段的JSON对象时，返回false，并保留原始响应数据。
+// 超过 MaxBodySize 的响应数据不做处理，仍以流式返回请求端。
+func (e *ResponseEnvelope) readFields(resp *flux.BackendResponse) (envelopeFields, bool, error) {
+	var data []byte
+	switch body := resp.Body.(type) {
+	case []byte:
+		data = body
+	case string:
+		data = []byte(body)
+	case io.Reader:
+		reader := body
+		if e.MaxBodySize > 0 {
+			reader = io.LimitReader(body, e.MaxBodySize+1)
+		}
+		buffer, err := ioutil.ReadAll(reader)
+		if nil != err {
+			if closer, ok := body.(io.Closer); ok {
+				_ = closer.Close()
+			}
+			return envelopeFields{}, false, err
+		}
+		if e.MaxBodySize > 0 && int64(len(buffer)) > e.MaxBodySize {
+			resp.Body = newPrefixedBody(buffer, body)
+			return envelopeFields{}, false, nil
+		}
+		if closer, ok := body.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(buffer))
+		data = buffer
+	default:
+		// 已解码的响应数据，例如Dubbo等RPC协议的返回值
+		values, err := cast.ToStringMapE(body)
+		if nil != err {
+			return envelopeFields{}, false, nil
+		}
+		code, ok := values[e.CodeField]
+		return envelopeFields{
+			code:    code,
+			message: cast.ToString(values[e.MessageField]),
+			data:    values[e.DataField],
+		}, ok, nil
+	}
+	raws := make(map[string]json.RawMessage)
+	if err := ext.JSONUnmarshal(data, &raws); nil != err {
+		return envelopeFields{}, false, nil
+	}
+	rawCode, ok := raws[e.CodeField]
+	if !ok {
+		return envelopeFields{}, false, nil
+	}
+	fields := envelopeFields{rawData: raws[e.DataField], raw: true}
+	if err := ext.JSONUnmarshal(rawCode, &fields.code); nil != err {
+		return envelopeFields{}, false, nil
+	}
+	if rawMessage, ok := raws[e.MessageField]; ok {
+		var message interface{}
+		if err := ext.JSONUnmarshal(rawMessage, &message); nil == err {
+			fields.message = cast.ToString(message)
+		}
+	}
+	// 响应数据将被替换，长度以替换后的数据为准
+	if nil != resp.Headers {
+		resp.Headers.Del(flux.HeaderContentLength)
+	}
+	return fields, true, nil
+}
+
+func (e *ResponseEnvelope) unwrap(resp *flux.BackendResponse, fields envelopeFields, status int) *flux.BackendResponse {
+	resp.StatusCode = status
+	if !fields.raw {
+		if e.Rewrap {
+			resp.Body = map[string]interface{}{"status": "success", "data": fields.data}
+		} else {
+			resp.Body = fields.data
+		}
+		return resp
+	}
+	// JSON响应数据：按原始数据输出data字段
+	data := []byte(fields.rawData)
+	if len(data) == 0 {
+		if !e.Rewrap {
+			resp.Body = nil
+			return resp
+		}
+		data = []byte("null")
+	}
+	if e.Rewrap {
+		buffer := bytes.NewBufferString(`{"status":"success","data":`)
+		buffer.Write(data)
+		buffer.WriteString("}")
+		data = buffer.Bytes()
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return resp
+}
+
+func (e *ResponseEnvelope) isSuccess(code string) bool {
+	for _, c := range e.SuccessCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixedBody 已读取部分数据的响应体，继续读取剩余数据
+type prefixedBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func newPrefixedBody(prefix []byte, body io.Reader) io.ReadCloser {
+	pb := &prefixedBody{Reader: io.MultiReader(bytes.NewReader(prefix), body)}
+	if closer, ok := body.(io.Closer); ok {
+		pb.closer = closer
+	}
+	return pb
+}
+
+func (p *prefixedBody) Close() error {
+	if nil != p.closer {
+		return p.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package backend
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseEnvelope_Decode(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	envelope, err := NewResponseEnvelopeOf("java", flux.NewConfigurationOfMap(map[string]interface{}{
+		"rewrap":        true,
+		"max_body_size": 64,
+		"mappings": []interface{}{
+			map[string]interface{}{"code": "A401", "status": 401, "error_code": "AUTH:EXPIRED"},
+		},
+	}))
+	tester.NoError(err)
+	newResponse := func(body string) *flux.BackendResponse {
+		return &flux.BackendResponse{
+			StatusCode: http.StatusOK,
+			Headers:    http.Header{flux.HeaderContentType: []string{flux.MIMEApplicationJSON}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+	readBody := func(resp *flux.BackendResponse) string {
+		data, err := ioutil.ReadAll(resp.Body.(io.Reader))
+		tester.NoError(err)
+		return string(data)
+	}
+	// 成功：解出data，并重新封装为网关标准信封；大整数不丢失精度
+	resp, serr := envelope.Decode(newResponse(`{"code":0,"msg":"ok","data":{"id":1234567890123456789}}`))
+	tester.Nil(serr)
+	tester.Equal(http.StatusOK, resp.StatusCode)
+	tester.Equal(`{"status":"success","data":{"id":1234567890123456789}}`, readBody(resp))
+	// 映射表声明的业务码
+	_, serr = envelope.Decode(newResponse(`{"code":"A401","msg":"token expired"}`))
+	tester.NotNil(serr)
+	tester.Equal(http.StatusUnauthorized, serr.StatusCode)
+	tester.Equal("AUTH:EXPIRED", serr.ErrorCode)
+	tester.Equal("token expired", serr.Message)
+	// 未声明的业务码
+	_, serr = envelope.Decode(newResponse(`{"code":"B500","msg":""}`))
+	tester.NotNil(serr)
+	tester.Equal(http.StatusBadGateway, serr.StatusCode)
+	tester.Equal("B500", serr.ErrorCode)
+	tester.Equal(flux.ErrorMessageEnvelopeBusinessError, serr.Message)
+	// 没有业务码字段：保留原始响应数据
+	resp, serr = envelope.Decode(newResponse(`{"id": 1.50}`))
+	tester.Nil(serr)
+	tester.Equal(`{"id": 1.50}`, readBody(resp))
+	// 超过长度限制：不做处理，完整返回原始响应数据
+	large := `{"code":0,"data":"` + strings.Repeat("x", 100) + `"}`
+	resp, serr = envelope.Decode(newResponse(large))
+	tester.Nil(serr)
+	tester.Equal(large, readBody(resp))
+	// RPC协议已解码的返回值
+	resp, serr = envelope.Decode(&flux.BackendResponse{
+		StatusCode: http.StatusOK,
+		Body:       map[string]interface{}{"code": 0, "data": map[string]interface{}{"id": 1}},
+	})
+	tester.Nil(serr)
+	tester.Equal(map[string]interface{}{"status": "success", "data": map[string]interface{}{"id": 1}}, resp.Body)
+}
@@ -0,0 +1,204 @@
+package fcgi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// FastCGI 协议定义
+// Ref: https://fast-cgi.github.io/spec
+const (
+	fcgiVersion = 1
+
+	typeBeginRequest = 1
+	typeEndRequest   = 3
+	typeParams       = 4
+	typeStdin        = 5
+	typeStdout       = 6
+	typeStderr       = 7
+
+	roleResponder = 1
+
+	// 单个Record的最大数据长度
+	maxContentLength = 65535
+	// 每个连接只执行一个请求，RequestId固定为1
+	requestId = 1
+)
+
+var (
+	ErrInvalidStatus = errors.New("BACKEND:FC:INVALID_STATUS")
+)
+
+type recordHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestId     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+// recordWriter 按Record写入FastCGI请求数据
+type recordWriter struct {
+	w   *bufio.Writer
+	buf bytes.Buffer
+}
+
+func newRecordWriter(w io.Writer) *recordWriter {
+	return &recordWriter{w: bufio.NewWriter(w)}
+}
+
+func (rw *recordWriter) writeRecord(recType uint8, content []byte) error {
+	header := recordHeader{
+		Version:       fcgiVersion,
+		Type:          recType,
+		RequestId:     requestId,
+		ContentLength: uint16(len(content)),
+		PaddingLength: uint8(-len(content) & 7),
+	}
+	if err := binary.Write(rw.w, binary.BigEndian, header); nil != err {
+		return err
+	}
+	if _, err := rw.w.Write(content); nil != err {
+		return err
+	}
+	_, err := rw.w.Write(make([]byte, header.PaddingLength))
+	return err
+}
+
+func (rw *recordWriter) writeBeginRequest() error {
+	// role, flags(不保持连接), reserved
+	content := []byte{0, roleResponder, 0, 0, 0, 0, 0, 0}
+	return rw.writeRecord(typeBeginRequest, content)
+}
+
+// writeParams 写入CGI参数，并以空的Params Record结束
+func (rw *recordWriter) writeParams(params map[string]string) error {
+	rw.buf.Reset()
+	for k, v := range params {
+		pair := encodePair(k, v)
+		if rw.buf.Len()+len(pair) > maxContentLength {
+			if err := rw.writeRecord(typeParams, rw.buf.Bytes()); nil != err {
+				return err
+			}
+			rw.buf.Reset()
+		}
+		rw.buf.Write(pair)
+	}
+	if rw.buf.Len() > 0 {
+		if err := rw.writeRecord(typeParams, rw.buf.Bytes()); nil != err {
+			return err
+		}
+	}
+	return rw.writeRecord(typeParams, nil)
+}
+
+// writeStdin 流式写入请求体数据，并以空的Stdin Record结束
+func (rw *recordWriter) writeStdin(body io.Reader) error {
+	if nil != body {
+		chunk := make([]byte, maxContentLength)
+		for {
+			n, err := body.Read(chunk)
+			if n > 0 {
+				if werr := rw.writeRecord(typeStdin, chunk[:n]); nil != werr {
+					return werr
+				}
+			}
+			if io.EOF == err {
+				break
+			} else if nil != err {
+				return err
+			}
+		}
+	}
+	if err := rw.writeRecord(typeStdin, nil); nil != err {
+		return err
+	}
+	return rw.w.Flush()
+}
+
+func encodePair(name, value string) []byte {
+	buf := make([]byte, 0, len(name)+len(value)+8)
+	buf = appendSize(buf, len(name))
+	buf = appendSize(buf, len(value))
+	buf = append(buf, name...)
+	return append(buf, value...)
+}
+
+func appendSize(buf []byte, size int) []byte {
+	if size <= 127 {
+		return append(buf, byte(size))
+	}
+	return append(buf, byte(size>>24)|0x80, byte(size>>16), byte(size>>8), byte(size))
+}
+
+// readStdout 读取FastCGI响应的Record，将Stdout数据写入Pipe；Stderr数据交由onStderr处理
+func readStdout(r io.Reader, out *io.PipeWriter, onStderr func([]byte)) {
+	reader := bufio.NewReader(r)
+	for {
+		var header recordHeader
+		if err := binary.Read(reader, binary.BigEndian, &header); nil != err {
+			if io.EOF == err {
+				err = io.ErrUnexpectedEOF
+			}
+			_ = out.CloseWithError(fmt.Errorf("read fastcgi record: %w", err))
+			return
+		}
+		content := make([]byte, int(header.ContentLength)+int(header.PaddingLength))
+		if _, err := io.ReadFull(reader, content); nil != err {
+			_ = out.CloseWithError(fmt.Errorf("read fastcgi record: %w", err))
+			return
+		}
+		content = content[:header.ContentLength]
+		switch header.Type {
+		case typeStdout:
+			if len(content) > 0 {
+				if _, err := out.Write(content); nil != err {
+					return
+				}
+			}
+		case typeStderr:
+			if len(content) > 0 && nil != onStderr {
+				onStderr(content)
+			}
+		case typeEndRequest:
+			// appStatus(4), protocolStatus(1)
+			if len(content) >= 5 && content[4] != 0 {
+				_ = out.CloseWithError(fmt.Errorf("fastcgi request rejected, protocol status: %d", content[4]))
+				return
+			}
+			_ = out.Close()
+			return
+		}
+	}
+}
+
+// readResponse 解析CGI响应：Header以空行结束，Status Header声明响应状态码，默认为200
+func readResponse(r io.Reader) (int, http.Header, *bufio.Reader, error) {
+	reader := bufio.NewReader(r)
+	mime, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if nil != err && !(io.EOF == err && len(mime) > 0) {
+		return 0, nil, nil, fmt.Errorf("read cgi headers: %w", err)
+	}
+	header := http.Header(mime)
+	status := http.StatusOK
+	if v := header.Get("Status"); "" != v {
+		code, err := strconv.Atoi(strings.SplitN(strings.TrimSpace(v), " ", 2)[0])
+		if nil != err || code < 100 || code > 999 {
+			return 0, nil, nil, fmt.Errorf("%w, status: %s", ErrInvalidStatus, v)
+		}
+		status = code
+		header.Del("Status")
+	} else if "" != header.Get("Location") {
+		status = http.StatusFound
+	}
+	return status, header, reader, nil
+}
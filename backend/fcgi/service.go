@@ -0,0 +1,365 @@
+package fcgi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigKeyDialTimeout = "dial_timeout"
+	ConfigKeyTraceEnable = "trace_enable"
+)
+
+// BackendService的属性
+const (
+	// ServiceAttrTagDocumentRoot 声明DOCUMENT_ROOT参数；SCRIPT_NAME为SCRIPT_FILENAME相对于DOCUMENT_ROOT的路径
+	ServiceAttrTagDocumentRoot = "documentroot"
+	// ServiceAttrTagParam 声明额外的CGI参数，可声明多个；格式为 "{name}={value}"
+	ServiceAttrTagParam = "fcgiparam"
+)
+
+const (
+	// SchemeUnix 使用Unix Socket连接；RemoteHost为Socket文件路径
+	SchemeUnix = "unix"
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoFastCGI, NewBackendTransportService())
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// ParamsAssembleFunc CGI参数封装函数
+	ParamsAssembleFunc func(service flux.BackendService, ctx flux.Context) (map[string]string, error)
+)
+
+// BackendTransportService 通过FastCGI协议调用PHP-FPM等服务的Transport；
+// BackendService.RemoteHost 为服务地址（TCP地址或Unix Socket路径），Interface 为SCRIPT_FILENAME。
+type BackendTransportService struct {
+	responseCodecFunc  flux.BackendResponseCodecFunc
+	paramsAssembleFunc ParamsAssembleFunc
+	dialTimeout        time.Duration
+	traceEnable        bool
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithParamsAssembleFunc 用于配置CGI参数封装实现函数
+func WithParamsAssembleFunc(fun ParamsAssembleFunc) Option {
+	return func(service *BackendTransportService) {
+		service.paramsAssembleFunc = fun
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith()
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		responseCodecFunc:  NewBackendResponseCodecFunc(),
+		paramsAssembleFunc: DefaultParamsAssembleFunc,
+		dialTimeout:        5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("FastCGI backend transport initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyDialTimeout: "5s",
+		ConfigKeyTraceEnable: false,
+	})
+	b.dialTimeout = config.GetDuration(ConfigKeyDialTimeout)
+	b.traceEnable = config.GetBool(ConfigKeyTraceEnable)
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.responseCodecFunc(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	return result, nil
+}
+
+// Invoke 执行FastCGI请求，返回 *http.Response；响应数据流式读取，读取完成后需要关闭Body
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	params, err := b.paramsAssembleFunc(service, ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageFastCGIAssembleFailed,
+			Internal:   err,
+		}
+	}
+	body, length, err := requestBodyOf(ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageFastCGIAssembleFailed,
+			Internal:   err,
+		}
+	}
+	if nil != body {
+		defer body.Close()
+	}
+	params["CONTENT_LENGTH"] = strconv.FormatInt(length, 10)
+	resp, err := b.execute(ctx, service, params, body)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageFastCGIInvokeFailed,
+			Internal:   err,
+		}
+	}
+	return resp, nil
+}
+
+// execute 连接服务并发送请求，读取响应Header；截止时间到达或响应数据流被关闭时，关闭连接
+func (b *BackendTransportService) execute(ctx flux.Context, service flux.BackendService, params map[string]string, body io.Reader) (*http.Response, error) {
+	goctx, cancel := ctx.Context(), context.CancelFunc(func() {})
+	if to := service.AttrRpcTimeout(); "" != to {
+		if timeout, err := time.ParseDuration(to); nil == err {
+			goctx, cancel = context.WithTimeout(goctx, timeout)
+		} else {
+			logger.WithContext(ctx).Warnw("Illegal service rpc-timeout", "timeout", to)
+		}
+	}
+	network, address := NewTargetAddress(service)
+	dialer := &net.Dialer{Timeout: b.dialTimeout}
+	conn, err := dialer.DialContext(goctx, network, address)
+	if nil != err {
+		cancel()
+		return nil, fmt.Errorf("dial fastcgi server, address: %s, err: %w", address, err)
+	}
+	closer := &connCloser{conn: conn, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-goctx.Done():
+			_ = closer.Close()
+		case <-closer.done:
+		}
+	}()
+	writer := newRecordWriter(conn)
+	if err := writer.writeBeginRequest(); nil != err {
+		_ = closer.Close()
+		return nil, fmt.Errorf("write fastcgi request: %w", err)
+	}
+	if err := writer.writeParams(params); nil != err {
+		_ = closer.Close()
+		return nil, fmt.Errorf("write fastcgi params: %w", err)
+	}
+	if err := writer.writeStdin(body); nil != err {
+		_ = closer.Close()
+		return nil, fmt.Errorf("write fastcgi stdin: %w", err)
+	}
+	pr, pw := io.Pipe()
+	go readStdout(conn, pw, func(data []byte) {
+		if b.traceEnable {
+			logger.WithContext(ctx).Warnw("BACKEND:FASTCGI:STDERR", "script", params["SCRIPT_FILENAME"], "stderr", string(data))
+		}
+	})
+	status, header, reader, err := readResponse(pr)
+	if nil != err {
+		_ = pr.Close()
+		_ = closer.Close()
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     header,
+		Body:       &responseBody{Reader: reader, pipe: pr, closer: closer},
+	}, nil
+}
+
+// DefaultParamsAssembleFunc 默认实现：按CGI/1.1规范，从网关请求构建CGI参数
+func DefaultParamsAssembleFunc(service flux.BackendService, ctx flux.Context) (map[string]string, error) {
+	if "" == service.Interface {
+		return nil, fmt.Errorf("fastcgi script filename is empty, service: %s", service.ServiceID())
+	}
+	request := ctx.Request()
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "Flux/Gateway",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"REQUEST_METHOD":    ctx.Method(),
+		"SCRIPT_FILENAME":   service.Interface,
+		"SCRIPT_NAME":       "/" + path.Base(service.Interface),
+		"REQUEST_URI":       ctx.URI(),
+		"REDIRECT_STATUS":   "200",
+	}
+	if root := service.GetAttr(ServiceAttrTagDocumentRoot).GetString(); "" != root {
+		params["DOCUMENT_ROOT"] = root
+		if strings.HasPrefix(service.Interface, root) {
+			params["SCRIPT_NAME"] = path.Clean("/" + strings.TrimPrefix(service.Interface, root))
+		}
+	}
+	if u := request.URL(); nil != u {
+		params["QUERY_STRING"] = u.RawQuery
+		params["DOCUMENT_URI"] = u.Path
+		if "" == params["REQUEST_URI"] {
+			params["REQUEST_URI"] = u.RequestURI()
+		}
+	}
+	if host, port, err := net.SplitHostPort(request.Address()); nil == err {
+		params["REMOTE_ADDR"], params["REMOTE_PORT"] = host, port
+	} else {
+		params["REMOTE_ADDR"] = request.Address()
+	}
+	// Header以HTTP_前缀传递；Content-Type和Content-Length使用CGI标准参数
+	header := request.HeaderVars()
+	for k, vs := range header {
+		switch ck := http.CanonicalHeaderKey(k); ck {
+		case flux.HeaderContentType:
+			params["CONTENT_TYPE"] = strings.Join(vs, ", ")
+		case flux.HeaderContentLength:
+		case "Proxy":
+			// httpoxy: https://httpoxy.org
+		default:
+			params[cgiHeaderName(ck)] = strings.Join(vs, ", ")
+		}
+	}
+	if host := header.Get("Host"); "" != host {
+		if name, port, err := net.SplitHostPort(host); nil == err {
+			params["SERVER_NAME"], params["SERVER_PORT"] = name, port
+		} else {
+			params["SERVER_NAME"] = host
+		}
+	}
+	for k, v := range ctx.Attributes() {
+		params[cgiHeaderName(k)] = cast.ToString(v)
+	}
+	for _, attr := range service.GetAttrs(ServiceAttrTagParam) {
+		kv := strings.SplitN(attr.GetString(), "=", 2)
+		if len(kv) != 2 || "" == strings.TrimSpace(kv[0]) {
+			return nil, fmt.Errorf("illegal fastcgi param: %s", attr.GetString())
+		}
+		params[strings.TrimSpace(kv[0])] = kv[1]
+	}
+	return params, nil
+}
+
+// NewTargetAddress 返回服务的网络类型和地址；Scheme为unix或RemoteHost为绝对路径时，使用Unix Socket
+func NewTargetAddress(service flux.BackendService) (network, address string) {
+	host := service.RemoteHost
+	if strings.HasPrefix(host, SchemeUnix+":") {
+		return SchemeUnix, strings.TrimPrefix(host, SchemeUnix+":")
+	}
+	if SchemeUnix == strings.ToLower(service.Scheme) || strings.HasPrefix(host, "/") {
+		return SchemeUnix, host
+	}
+	return "tcp", host
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, raw interface{}) (*flux.BackendResponse, error) {
+		resp, ok := raw.(*http.Response)
+		if !ok {
+			return nil, fmt.Errorf("unknown fastcgi backend response: %T", raw)
+		}
+		return &flux.BackendResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header,
+			Body:       resp.Body,
+		}, nil
+	}
+}
+
+// requestBodyOf 返回请求体及其长度；请求没有声明Content-Length时，读取请求体以计算长度
+func requestBodyOf(ctx flux.Context) (io.ReadCloser, int64, error) {
+	body, err := ctx.Request().BodyReader()
+	if nil != err || nil == body {
+		return nil, 0, err
+	}
+	if length, err := strconv.ParseInt(ctx.Request().HeaderVars().Get(flux.HeaderContentLength), 10, 64); nil == err && length >= 0 {
+		return body, length, nil
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if nil != err {
+		return nil, 0, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func cgiHeaderName(name string) string {
+	return "HTTP_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// connCloser 关闭连接并释放rpc-timeout的Context
+type connCloser struct {
+	conn   net.Conn
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (c *connCloser) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+		c.cancel()
+	})
+	return err
+}
+
+// responseBody 响应数据读取完成后，关闭连接
+type responseBody struct {
+	io.Reader
+	pipe   *io.PipeReader
+	closer *connCloser
+}
+
+func (r *responseBody) Close() error {
+	_ = r.pipe.Close()
+	return r.closer.Close()
+}
@@ -0,0 +1,77 @@
+package fcgi
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBackendTransportService_Invoke(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	tester.NoError(err)
+	defer listener.Close()
+	// 标准库的FastCGI服务端：回写CGI参数和请求体
+	go fcgi.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := fcgi.ProcessEnv(r)
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Script", env["SCRIPT_FILENAME"])
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Header().Set("X-Query", r.URL.Query().Get("id"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(r.Method + ":" + string(body)))
+	}))
+	service := flux.BackendService{
+		RemoteHost: listener.Addr().String(),
+		Interface:  "/var/www/app/index.php",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: ServiceAttrTagDocumentRoot, Value: "/var/www/app"},
+		}},
+	}
+	ctx := context.NewMockContext(map[string]interface{}{
+		"method":        http.MethodPost,
+		"url":           &url.URL{Path: "/orders", RawQuery: "id=10"},
+		"header-values": http.Header{"X-Token": []string{"t1"}, "Host": []string{"gateway:8080"}},
+		"body":          ioutil.NopCloser(strings.NewReader("hello")),
+	})
+	transport := NewBackendTransportService()
+	resp, serr := transport.InvokeCodec(ctx, service)
+	tester.Nil(serr)
+	tester.Equal(http.StatusCreated, resp.StatusCode)
+	tester.Equal("/var/www/app/index.php", resp.Headers.Get("X-Script"))
+	tester.Equal("t1", resp.Headers.Get("X-Token"))
+	tester.Equal("10", resp.Headers.Get("X-Query"))
+	body := resp.Body.(io.ReadCloser)
+	data, err := ioutil.ReadAll(body)
+	tester.NoError(err)
+	tester.Equal("POST:hello", string(data))
+	tester.NoError(body.Close())
+	// 服务不可用
+	_, serr = transport.InvokeCodec(ctx, flux.BackendService{RemoteHost: "127.0.0.1:1", Interface: "/index.php"})
+	tester.NotNil(serr)
+	tester.Equal(flux.ErrorMessageFastCGIInvokeFailed, serr.Message)
+}
+
+func TestNewTargetAddress(t *testing.T) {
+	tester := assert.New(t)
+	network, address := NewTargetAddress(flux.BackendService{RemoteHost: "/run/php-fpm.sock"})
+	tester.Equal("unix", network)
+	tester.Equal("/run/php-fpm.sock", address)
+	network, address = NewTargetAddress(flux.BackendService{RemoteHost: "unix:/run/php.sock"})
+	tester.Equal("unix", network)
+	tester.Equal("/run/php.sock", address)
+	network, address = NewTargetAddress(flux.BackendService{RemoteHost: "php:9000"})
+	tester.Equal("tcp", network)
+	tester.Equal("php:9000", address)
+}
@@ -0,0 +1,58 @@
+package grpc
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"io/ioutil"
+)
+
+var (
+	_jsonpbUnmarshaler = &jsonpb.Unmarshaler{AllowUnknownFields: true}
+)
+
+// DefaultArgAssembleFunc 默认实现gRPC参数封装：将Arguments按参数名映射到请求消息的同名字段。
+// 如果未定义参数，则将Http请求的JSON Body作为请求消息透传。
+// 注意：参数值通过JSON中间格式转换为Protobuf字段值，由jsonpb完成数值、枚举等类型的转换；
+func DefaultArgAssembleFunc(input *desc.MessageDescriptor, arguments []flux.Argument, ctx flux.Context) (proto.Message, error) {
+	message := dynamic.NewMessage(input)
+	var data []byte
+	if len(arguments) > 0 {
+		values := make(map[string]interface{}, len(arguments))
+		for _, arg := range arguments {
+			if val, err := arg.Resolve(ctx); nil != err {
+				return nil, err
+			} else {
+				values[arg.Name] = val
+			}
+		}
+		if bytes, err := ext.JSONMarshal(values); nil != err {
+			return nil, fmt.Errorf("grpc encode arguments, message: %s, err: %w", input.GetFullyQualifiedName(), err)
+		} else {
+			data = bytes
+		}
+	} else {
+		reader, err := ctx.Request().BodyReader()
+		if nil != err {
+			return nil, err
+		}
+		if nil != reader {
+			data, err = ioutil.ReadAll(reader)
+			_ = reader.Close()
+			if nil != err {
+				return nil, err
+			}
+		}
+	}
+	if len(data) == 0 {
+		return message, nil
+	}
+	if err := message.UnmarshalJSONPB(_jsonpbUnmarshaler, data); nil != err {
+		return nil, fmt.Errorf("grpc assemble message: %s, err: %w", input.GetFullyQualifiedName(), err)
+	}
+	return message, nil
+}
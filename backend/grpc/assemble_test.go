@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// stdJsonSerializer 测试使用标准库JSON序列化
+type stdJsonSerializer struct{}
+
+func (s *stdJsonSerializer) Marshal(any interface{}) ([]byte, error) {
+	return json.Marshal(any)
+}
+
+func (s *stdJsonSerializer) Unmarshal(data []byte, obj interface{}) error {
+	return json.Unmarshal(data, obj)
+}
+
+func TestDefaultArgAssembleFunc(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	ext.SetSerializer(ext.TypeNameSerializerJson, new(stdJsonSerializer))
+	input, err := desc.LoadMessageDescriptorForMessage(&grpc_health_v1.HealthCheckRequest{})
+	tester.NoError(err)
+	cases := []struct {
+		name      string
+		arguments []flux.Argument
+		values    map[string]interface{}
+		expected  string
+		err       bool
+	}{
+		{
+			name:      "arguments",
+			arguments: []flux.Argument{ext.NewStringArgument("service")},
+			values:    map[string]interface{}{"service": "user"},
+			expected:  "user",
+		},
+		{
+			name:      "ignore unknown arguments",
+			arguments: []flux.Argument{ext.NewStringArgument("service"), ext.NewStringArgument("unknown")},
+			values:    map[string]interface{}{"service": "user", "unknown": "x"},
+			expected:  "user",
+		},
+		{
+			name:     "json body",
+			values:   map[string]interface{}{"body": ioutil.NopCloser(strings.NewReader(`{"service":"order"}`))},
+			expected: "order",
+		},
+		{
+			name:     "empty body",
+			values:   map[string]interface{}{"body": ioutil.NopCloser(strings.NewReader(""))},
+			expected: "",
+		},
+		{
+			name:   "illegal json body",
+			values: map[string]interface{}{"body": ioutil.NopCloser(strings.NewReader(`{"service":`))},
+			err:    true,
+		},
+	}
+	for _, c := range cases {
+		message, err := DefaultArgAssembleFunc(input, c.arguments, context.NewMockContext(c.values))
+		if c.err {
+			tester.Error(err, c.name)
+			continue
+		}
+		tester.NoError(err, c.name)
+		tester.Equal(c.expected, message.(*dynamic.Message).GetFieldByName("service"), c.name)
+	}
+}
@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"errors"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/ext"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+var (
+	ErrUnknownGrpcBackendResponse = errors.New("BACKEND:UNKNOWN_GRPC_RESPONSE")
+)
+
+var (
+	_jsonpbMarshaler = &jsonpb.Marshaler{OrigName: true}
+)
+
+// Response gRPC调用返回的原始响应数据
+type Response struct {
+	Header  metadata.MD
+	Message proto.Message
+}
+
+// NewBackendResponseCodecFunc 默认实现：将gRPC响应消息解析为JSON对象结构的Body；
+// 响应Header的Metadata，作为Attachments返回；
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, raw interface{}) (*flux.BackendResponse, error) {
+		resp, ok := raw.(*Response)
+		if !ok {
+			return &flux.BackendResponse{
+				StatusCode: http.StatusBadGateway,
+				Headers:    make(http.Header, 0),
+				Body:       nil,
+			}, ErrUnknownGrpcBackendResponse
+		}
+		message, err := dynamic.AsDynamicMessage(resp.Message)
+		if nil != err {
+			return nil, err
+		}
+		data, err := message.MarshalJSONPB(_jsonpbMarshaler)
+		if nil != err {
+			return nil, err
+		}
+		body := make(map[string]interface{}, 8)
+		if err := ext.JSONUnmarshal(data, &body); nil != err {
+			return nil, err
+		}
+		attrs := make(map[string]interface{}, len(resp.Header))
+		for k, v := range resp.Header {
+			if len(v) > 0 {
+				attrs[k] = v[0]
+			}
+		}
+		return &flux.BackendResponse{
+			StatusCode: flux.StatusOK, Headers: make(http.Header, 0), Attachments: attrs, Body: body,
+		}, nil
+	}
+}
+
+// NewInvokeServeError 将gRPC调用错误转换为ServeError；状态码按gRPC的Code映射为Http状态码
+func NewInvokeServeError(err error) *flux.ServeError {
+	serr := &flux.ServeError{
+		StatusCode: flux.StatusBadGateway,
+		ErrorCode:  flux.ErrorCodeGatewayBackend,
+		Message:    flux.ErrorMessageGrpcInvokeFailed,
+		Internal:   err,
+	}
+	if s, ok := status.FromError(err); ok {
+		serr.StatusCode = HttpStatusFromCode(s.Code())
+		serr.PutExtraTrace("grpc-code", s.Code().String())
+		serr.PutExtraTrace("grpc-message", s.Message())
+	}
+	return serr
+}
+
+// HttpStatusFromCode 映射gRPC状态码到Http状态码
+// Ref: https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+func HttpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"net/http"
+	"testing"
+)
+
+func TestHttpStatusFromCode(t *testing.T) {
+	cases := []struct {
+		code     codes.Code
+		expected int
+	}{
+		{code: codes.OK, expected: http.StatusOK},
+		{code: codes.Canceled, expected: 499},
+		{code: codes.Unknown, expected: http.StatusBadGateway},
+		{code: codes.InvalidArgument, expected: http.StatusBadRequest},
+		{code: codes.DeadlineExceeded, expected: http.StatusGatewayTimeout},
+		{code: codes.NotFound, expected: http.StatusNotFound},
+		{code: codes.AlreadyExists, expected: http.StatusConflict},
+		{code: codes.PermissionDenied, expected: http.StatusForbidden},
+		{code: codes.ResourceExhausted, expected: http.StatusTooManyRequests},
+		{code: codes.FailedPrecondition, expected: http.StatusBadRequest},
+		{code: codes.Aborted, expected: http.StatusConflict},
+		{code: codes.OutOfRange, expected: http.StatusBadRequest},
+		{code: codes.Unimplemented, expected: http.StatusNotImplemented},
+		{code: codes.Internal, expected: http.StatusBadGateway},
+		{code: codes.Unavailable, expected: http.StatusServiceUnavailable},
+		{code: codes.DataLoss, expected: http.StatusBadGateway},
+		{code: codes.Unauthenticated, expected: http.StatusUnauthorized},
+		{code: codes.Code(99), expected: http.StatusBadGateway},
+	}
+	tester := assert.New(t)
+	for _, c := range cases {
+		tester.Equal(c.expected, HttpStatusFromCode(c.code), "code: %s", c.code)
+	}
+}
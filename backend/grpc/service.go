@@ -0,0 +1,398 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+)
+
+import (
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+const (
+	ConfigKeyDescriptorSets   = "descriptor_sets"
+	ConfigKeyReflectionEnable = "reflection_enable"
+	ConfigKeyTLSEnable        = "tls_enable"
+	ConfigKeyDialTimeout      = "dial_timeout"
+	ConfigKeyTraceEnable      = "trace_enable"
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoGRPC, NewBackendTransportService())
+}
+
+var (
+	ErrMethodNotFound = errors.New(flux.ErrorMessageGrpcMethodNotFound)
+)
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// ArgumentsAssembleFunc gRPC调用参数封装函数，将Arguments封装为Method的请求消息对象
+	ArgumentsAssembleFunc func(input *desc.MessageDescriptor, arguments []flux.Argument, ctx flux.Context) (proto.Message, error)
+	// MetadataAssembleFunc 封装gRPC请求Metadata的函数
+	MetadataAssembleFunc func(ctx flux.Context) (metadata.MD, error)
+)
+
+// BackendTransportService 基于gRPC动态调用的BackendService；
+// 通过服务端反射(Server Reflection)或者配置的DescriptorSet文件来解析服务方法描述。
+type BackendTransportService struct {
+	// 可外部配置
+	defaults          map[string]interface{}        // 配置默认值
+	argAssembleFunc   ArgumentsAssembleFunc         // gRPC参数封装函数
+	mdAssembleFunc    MetadataAssembleFunc          // Metadata封装函数
+	responseCodecFunc flux.BackendResponseCodecFunc // 解析响应结果的函数
+	// 内部私有
+	traceEnable      bool
+	reflectionEnable bool
+	tlsEnable        bool
+	dialTimeout      time.Duration
+	services         map[string]*desc.ServiceDescriptor // 从DescriptorSet加载的服务描述
+	methods          sync.Map                           // 已解析的方法描述缓存
+	conns            map[string]*grpc.ClientConn        // 按RemoteHost缓存的连接
+	reflects         map[string]*grpcreflect.Client     // 按RemoteHost缓存的反射客户端
+	connMutex        sync.Mutex
+}
+
+// WithArgumentAssembleFunc 用于配置gRPC参数封装实现函数
+func WithArgumentAssembleFunc(fun ArgumentsAssembleFunc) Option {
+	return func(service *BackendTransportService) {
+		service.argAssembleFunc = fun
+	}
+}
+
+// WithMetadataAssembleFunc 用于配置Metadata封装实现函数
+func WithMetadataAssembleFunc(fun MetadataAssembleFunc) Option {
+	return func(service *BackendTransportService) {
+		service.mdAssembleFunc = fun
+	}
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithDefaults 用于配置默认配置值
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(service *BackendTransportService) {
+		service.defaults = defaults
+	}
+}
+
+// NewBackendTransportServiceWith New grpc backend service with options
+func NewBackendTransportServiceWith(opts ...Option) flux.BackendTransport {
+	bts := &BackendTransportService{
+		services: make(map[string]*desc.ServiceDescriptor, 16),
+		conns:    make(map[string]*grpc.ClientConn, 8),
+		reflects: make(map[string]*grpcreflect.Client, 8),
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+// NewBackendTransportService New grpc backend instance
+func NewBackendTransportService() flux.BackendTransport {
+	return NewBackendTransportServiceOverrides()
+}
+
+// NewBackendTransportServiceOverrides New grpc backend instance
+func NewBackendTransportServiceOverrides(overrides ...Option) flux.BackendTransport {
+	opts := []Option{
+		WithArgumentAssembleFunc(DefaultArgAssembleFunc),
+		WithMetadataAssembleFunc(DefaultMetadataAssembleFunc),
+		WithResponseCodecFunc(NewBackendResponseCodecFunc()),
+		WithDefaults(map[string]interface{}{
+			ConfigKeyReflectionEnable: true,
+			ConfigKeyTLSEnable:        false,
+			ConfigKeyTraceEnable:      false,
+			ConfigKeyDialTimeout:      "5s",
+		}),
+	}
+	return NewBackendTransportServiceWith(append(opts, overrides...)...)
+}
+
+// GetResponseCodecFunc returns result decode func
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+// Init init backend
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("gRPC backend transport initializing")
+	config.SetDefaults(b.defaults)
+	b.traceEnable = config.GetBool(ConfigKeyTraceEnable)
+	b.reflectionEnable = config.GetBool(ConfigKeyReflectionEnable)
+	b.tlsEnable = config.GetBool(ConfigKeyTLSEnable)
+	b.dialTimeout = config.GetDuration(ConfigKeyDialTimeout)
+	if pkg.IsNil(b.argAssembleFunc) {
+		b.argAssembleFunc = DefaultArgAssembleFunc
+	}
+	if pkg.IsNil(b.mdAssembleFunc) {
+		b.mdAssembleFunc = DefaultMetadataAssembleFunc
+	}
+	for _, file := range config.GetStringSlice(ConfigKeyDescriptorSets) {
+		if err := b.loadDescriptorSet(file); nil != err {
+			return err
+		}
+	}
+	logger.Infow("gRPC backend transport config",
+		"reflection-enable", b.reflectionEnable, "tls-enable", b.tlsEnable, "descriptor-services", len(b.services))
+	return nil
+}
+
+// Startup startup service
+func (b *BackendTransportService) Startup() error {
+	return nil
+}
+
+// Shutdown shutdown service
+func (b *BackendTransportService) Shutdown(_ context.Context) error {
+	b.connMutex.Lock()
+	defer b.connMutex.Unlock()
+	for host, rc := range b.reflects {
+		rc.Reset()
+		delete(b.reflects, host)
+	}
+	for host, conn := range b.conns {
+		if err := conn.Close(); nil != err {
+			logger.Warnw("gRPC close connection", "remote-host", host, "error", err)
+		}
+		delete(b.conns, host)
+	}
+	return nil
+}
+
+// Exchange do exchange with context
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+// Invoke invoke backend service with context
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	conn, err := b.LoadConnection(service.RemoteHost)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageGrpcInvokeFailed,
+			Internal:   err,
+		}
+	}
+	method, err := b.LoadMethod(conn, &service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageGrpcMethodNotFound,
+			Internal:   err,
+		}
+	}
+	request, err := b.argAssembleFunc(method.GetInputType(), service.Arguments, ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageGrpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	return b.DoInvoke(conn, method, request, service, ctx)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	// decode response
+	result, err := b.GetResponseCodecFunc()(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   fmt.Errorf("decode grpc response, err: %w", err),
+		}
+	}
+	return result, nil
+}
+
+// DoInvoke execute unary rpc method with request message
+func (b *BackendTransportService) DoInvoke(conn *grpc.ClientConn, method *desc.MethodDescriptor, request proto.Message,
+	service flux.BackendService, ctx flux.Context) (interface{}, *flux.ServeError) {
+	if b.traceEnable {
+		logger.WithContext(ctx).Infow("BACKEND:GRPC:INVOKE",
+			"backend-service", service.ServiceID(), "request", request.String(), "attrs", ctx.Attributes())
+	}
+	md, err := b.mdAssembleFunc(ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageGrpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	goctx := metadata.NewOutgoingContext(ctx.Context(), md)
+	if to := service.AttrRpcTimeout(); "" != to {
+		if timeout, err := time.ParseDuration(to); nil == err {
+			var cancel context.CancelFunc
+			goctx, cancel = context.WithTimeout(goctx, timeout)
+			defer cancel()
+		} else {
+			logger.WithContext(ctx).Warnw("Illegal service rpc-timeout", "timeout", to)
+		}
+	}
+	var header metadata.MD
+	response, err := grpcdynamic.NewStub(conn).InvokeRpc(goctx, method, request, grpc.Header(&header))
+	if nil != err {
+		logger.WithContext(ctx).Errorw("BACKEND:GRPC:RPC_ERROR",
+			"backend-service", service.ServiceID(), "error", err)
+		return nil, NewInvokeServeError(err)
+	}
+	if b.traceEnable {
+		logger.WithContext(ctx).Infow("BACKEND:GRPC:RECEIVED",
+			"backend-service", service.ServiceID(), "response", response.String())
+	}
+	return &Response{Header: header, Message: response}, nil
+}
+
+// LoadConnection 加载或者创建RemoteHost的连接；创建连接时不持有锁，并发创建的多余连接被关闭
+func (b *BackendTransportService) LoadConnection(host string) (*grpc.ClientConn, error) {
+	if "" == host {
+		return nil, errors.New("grpc remote-host is empty")
+	}
+	b.connMutex.Lock()
+	conn, ok := b.conns[host]
+	b.connMutex.Unlock()
+	if ok {
+		return conn, nil
+	}
+	opts := make([]grpc.DialOption, 0, 3)
+	if b.tlsEnable {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	// 阻塞等待连接建立，dial_timeout 才能限制建立连接的时间；连接失败时不缓存连接
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if b.dialTimeout > 0 {
+		opts = append(opts, grpc.WithBlock())
+		ctx, cancel = context.WithTimeout(ctx, b.dialTimeout)
+	}
+	defer cancel()
+	logger.Infow("Create grpc connection", "remote-host", host)
+	newConn, err := grpc.DialContext(ctx, host, opts...)
+	if nil != err {
+		return nil, fmt.Errorf("dial grpc remote-host: %s, err: %w", host, err)
+	}
+	b.connMutex.Lock()
+	defer b.connMutex.Unlock()
+	if conn, ok := b.conns[host]; ok {
+		_ = newConn.Close()
+		return conn, nil
+	}
+	b.conns[host] = newConn
+	return newConn, nil
+}
+
+// LoadMethod 查找服务方法的描述；优先使用DescriptorSet定义，其次通过服务端反射查询。
+func (b *BackendTransportService) LoadMethod(conn *grpc.ClientConn, service *flux.BackendService) (*desc.MethodDescriptor, error) {
+	key := service.RemoteHost + "/" + service.Interface + "/" + service.Method
+	if md, ok := b.methods.Load(key); ok {
+		return md.(*desc.MethodDescriptor), nil
+	}
+	sd, ok := b.services[service.Interface]
+	if !ok {
+		if !b.reflectionEnable {
+			return nil, fmt.Errorf("grpc service not found, service: %s, %w", service.Interface, ErrMethodNotFound)
+		}
+		resolved, err := b.loadReflectClient(conn, service.RemoteHost).ResolveService(service.Interface)
+		if nil != err {
+			return nil, fmt.Errorf("grpc reflect service: %s, err: %w", service.Interface, err)
+		}
+		sd = resolved
+	}
+	md := sd.FindMethodByName(service.Method)
+	if nil == md {
+		return nil, fmt.Errorf("grpc method not found, service: %s, method: %s, %w", service.Interface, service.Method, ErrMethodNotFound)
+	}
+	if md.IsClientStreaming() || md.IsServerStreaming() {
+		return nil, fmt.Errorf("grpc streaming method not supported, service: %s, method: %s", service.Interface, service.Method)
+	}
+	b.methods.Store(key, md)
+	return md, nil
+}
+
+func (b *BackendTransportService) loadReflectClient(conn *grpc.ClientConn, host string) *grpcreflect.Client {
+	b.connMutex.Lock()
+	defer b.connMutex.Unlock()
+	if rc, ok := b.reflects[host]; ok {
+		return rc
+	}
+	rc := grpcreflect.NewClient(context.Background(), rpb.NewServerReflectionClient(conn))
+	b.reflects[host] = rc
+	return rc
+}
+
+func (b *BackendTransportService) loadDescriptorSet(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if nil != err {
+		return fmt.Errorf("grpc read descriptor-set, path: %s, err: %w", file, err)
+	}
+	fds := new(dpb.FileDescriptorSet)
+	if err := proto.Unmarshal(data, fds); nil != err {
+		return fmt.Errorf("grpc decode descriptor-set, path: %s, err: %w", file, err)
+	}
+	files, err := desc.CreateFileDescriptorsFromSet(fds)
+	if nil != err {
+		return fmt.Errorf("grpc create descriptors, path: %s, err: %w", file, err)
+	}
+	for _, fd := range files {
+		for _, sd := range fd.GetServices() {
+			logger.Infow("gRPC load service descriptor", "service", sd.GetFullyQualifiedName(), "path", file)
+			b.services[sd.GetFullyQualifiedName()] = sd
+		}
+	}
+	return nil
+}
+
+// DefaultMetadataAssembleFunc 默认实现：将Context.Attributes封装为gRPC的Metadata
+func DefaultMetadataAssembleFunc(ctx flux.Context) (metadata.MD, error) {
+	md := make(metadata.MD, 8)
+	for k, v := range ctx.Attributes() {
+		if sv, err := cast.ToStringE(v); nil == err {
+			md.Append(k, sv)
+		}
+	}
+	return md, nil
+}
@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	goctx "context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackendTransportService_Invoke(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	ext.SetSerializer(ext.TypeNameSerializerJson, new(stdJsonSerializer))
+	// 进程内gRPC服务：健康检查服务，通过服务端反射解析方法描述
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	tester.NoError(err)
+	server := grpc.NewServer()
+	checker := health.NewServer()
+	checker.SetServingStatus("user", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, checker)
+	reflection.Register(server)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+	transport := NewBackendTransportService().(*BackendTransportService)
+	tester.NoError(transport.Init(flux.NewConfigurationOfMap(map[string]interface{}{})))
+	defer func() {
+		_ = transport.Shutdown(goctx.Background())
+	}()
+	newService := func(method string) flux.BackendService {
+		return flux.BackendService{
+			RemoteHost: listener.Addr().String(),
+			Interface:  "grpc.health.v1.Health",
+			Method:     method,
+			Arguments:  []flux.Argument{ext.NewStringArgument("service")},
+			EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoGRPC},
+			}},
+		}
+	}
+	newContext := func(service string) flux.Context {
+		return context.NewMockContext(map[string]interface{}{
+			"request-id": "req-1",
+			"service":    service,
+		})
+	}
+	// 调用成功，响应消息解析为JSON对象
+	resp, serr := transport.InvokeCodec(newContext("user"), newService("Check"))
+	tester.Nil(serr)
+	tester.Equal(flux.StatusOK, resp.StatusCode)
+	tester.Equal(map[string]interface{}{"status": "NOT_SERVING"}, resp.Body)
+	// gRPC错误码映射为Http状态码
+	_, serr = transport.InvokeCodec(newContext("missing"), newService("Check"))
+	tester.NotNil(serr)
+	tester.Equal(http.StatusNotFound, serr.StatusCode)
+	tester.Equal(flux.ErrorCodeGatewayBackend, serr.ErrorCode)
+	// 方法不存在
+	_, serr = transport.InvokeCodec(newContext("user"), newService("Missing"))
+	tester.NotNil(serr)
+	tester.Equal(flux.ErrorMessageGrpcMethodNotFound, serr.Message)
+	// 流式方法不支持
+	_, serr = transport.InvokeCodec(newContext("user"), newService("Watch"))
+	tester.NotNil(serr)
+	// 复用同一个连接
+	conn1, err := transport.LoadConnection(listener.Addr().String())
+	tester.NoError(err)
+	conn2, err := transport.LoadConnection(listener.Addr().String())
+	tester.NoError(err)
+	tester.Equal(conn1, conn2)
+	// 连接超时
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	tester.NoError(err)
+	_ = closed.Close()
+	transport.dialTimeout = 100 * time.Millisecond
+	_, err = transport.LoadConnection(closed.Addr().String())
+	tester.Error(err)
+}
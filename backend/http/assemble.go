@@ -1,77 +1,275 @@
 package http
 
 import (
-	"context"
+	"bytes"
 	"fmt"
 	"github.com/bytepowered/flux"
-	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/ext"
 	"github.com/spf13/cast"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
-	"time"
 )
 
+const (
+	// ServiceAttrTagBodyEncoding 非GET请求时，声明参数的Body编码格式：[form, json, multipart]；默认为form
+	ServiceAttrTagBodyEncoding = "bodyencoding"
+)
+
+// Body编码格式
+const (
+	BodyEncodingForm      = "form"
+	BodyEncodingJSON      = "json"
+	BodyEncodingMultipart = "multipart"
+)
+
+var (
+	// 路径模板变量：{name}
+	pathTemplatePattern = regexp.MustCompile(`\{([^/{}]+)}`)
+)
+
+// PlacedArguments 按后端请求位置分组的参数
+type PlacedArguments struct {
+	Path   []flux.Argument
+	Query  []flux.Argument
+	Header []flux.Argument
+	Cookie []flux.Argument
+	Body   []flux.Argument
+}
+
 func DefaultArgumentAssemble(service *flux.BackendService, inURL *url.URL, bodyReader io.ReadCloser, ctx flux.Context) (*http.Request, error) {
-	inParams := service.Arguments
 	newQuery := inURL.RawQuery
 	// 使用可重复读的GetBody函数
 	defer func() {
 		_ = bodyReader.Close()
 	}()
-	var newBodyReader io.Reader = bodyReader
-	if len(inParams) > 0 {
-		// 如果Endpoint定义了参数，即表示限定参数传递
-		var data string
-		if values, err := AssembleHttpValues(inParams, ctx); nil != err {
+	placed, err := PlaceArguments(service.Interface, service.Method, service.Arguments)
+	if nil != err {
+		return nil, err
+	}
+	// Interface路径模板：{name}
+	newPath, newRawPath, err := AssembleHttpPath(service.Interface, placed.Path, ctx)
+	if nil != err {
+		return nil, err
+	}
+	if len(placed.Query) > 0 {
+		values, err := AssembleHttpValues(placed.Query, ctx)
+		if nil != err {
 			return nil, err
+		}
+		if data := values.Encode(); newQuery == "" {
+			newQuery = data
 		} else {
-			data = values.Encode()
+			newQuery += "&" + data
 		}
-		// GET：参数拼接到URL中；
-		if http.MethodGet == service.Method {
-			if newQuery == "" {
-				newQuery = data
-			} else {
-				newQuery += "&" + data
+	}
+	var newBodyReader io.Reader = bodyReader
+	var contentType string
+	if len(service.Arguments) > 0 {
+		// 如果Endpoint定义了参数，即表示限定参数传递；按服务声明的编码格式，封装到Body中
+		newBodyReader = nil
+		if len(placed.Body) > 0 {
+			body, ctype, err := AssembleHttpBody(service.GetAttr(ServiceAttrTagBodyEncoding).GetString(), placed.Body, ctx)
+			if nil != err {
+				return nil, err
 			}
-		} else {
-			// 其它方法：拼接到Body中，并设置form-data/x-www-url-encoded
-			newBodyReader = strings.NewReader(data)
+			newBodyReader, contentType = body, ctype
 		}
 	}
 	// 未定义参数，即透传Http请求：Rewrite inRequest path
 	newUrl := &url.URL{
 		Host:       service.RemoteHost,
-		Path:       service.Interface,
+		Path:       newPath,
+		RawPath:    newRawPath,
 		Scheme:     service.Scheme,
 		Opaque:     inURL.Opaque,
 		User:       inURL.User,
-		RawPath:    inURL.RawPath,
 		ForceQuery: inURL.ForceQuery,
 		RawQuery:   newQuery,
 		Fragment:   inURL.Fragment,
 	}
-	to := service.AttrRpcTimeout()
-	timeout, err := time.ParseDuration(to)
-	if err != nil {
-		logger.Warnf("Illegal endpoint rpc-timeout: ", to)
-		timeout = time.Second * 10
-	}
-	toctx, _ := context.WithTimeout(ctx.Context(), timeout)
-	newRequest, err := http.NewRequestWithContext(toctx, service.Method, newUrl.String(), newBodyReader)
+	// 请求截止时间由Context控制；服务声明的rpc-timeout在执行请求时设置
+	newRequest, err := http.NewRequestWithContext(ctx.Context(), service.Method, newUrl.String(), newBodyReader)
 	if nil != err {
 		return nil, fmt.Errorf("new request, method: %s, url: %s, err: %w", service.Method, newUrl, err)
 	}
-	// Body数据设置application/x-www-url-encoded
-	if http.MethodGet != service.Method {
-		newRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// 封装参数的Body数据，设置对应的ContentType；透传请求时，保留原请求的ContentType
+	if "" != contentType {
+		newRequest.Header.Set(flux.HeaderContentType, contentType)
 	}
 	newRequest.Header.Set("User-Agent", "FluxGo/Backend/v1")
+	if len(placed.Header) > 0 {
+		values, err := AssembleHttpValues(placed.Header, ctx)
+		if nil != err {
+			return nil, err
+		}
+		for name, vs := range values {
+			newRequest.Header[http.CanonicalHeaderKey(name)] = vs
+		}
+	}
+	if len(placed.Cookie) > 0 {
+		values, err := AssembleHttpValues(placed.Cookie, ctx)
+		if nil != err {
+			return nil, err
+		}
+		for name, vs := range values {
+			for _, v := range vs {
+				newRequest.AddCookie(&http.Cookie{Name: name, Value: v})
+			}
+		}
+	}
 	return newRequest, err
 }
 
+// PlaceArguments 按参数声明的TargetScope分组；未声明时，与路径模板变量同名的参数作为PATH参数，
+// 其它参数GET请求为QUERY，其它方法为BODY。
+func PlaceArguments(pathTemplate, method string, arguments []flux.Argument) (PlacedArguments, error) {
+	placed := PlacedArguments{}
+	vars := make(map[string]struct{}, 2)
+	for _, m := range pathTemplatePattern.FindAllStringSubmatch(pathTemplate, -1) {
+		vars[m[1]] = struct{}{}
+	}
+	for _, arg := range arguments {
+		scope := strings.ToUpper(arg.TargetScope)
+		if "" == scope {
+			if _, ok := vars[arg.Name]; ok {
+				scope = flux.ScopePath
+			} else if http.MethodGet == method {
+				scope = flux.ScopeQuery
+			} else {
+				scope = flux.ScopeBody
+			}
+		}
+		switch scope {
+		case flux.ScopePath:
+			if _, ok := vars[arg.Name]; !ok {
+				return placed, fmt.Errorf("path argument not found in path template, argument: %s, path: %s", arg.Name, pathTemplate)
+			}
+			placed.Path = append(placed.Path, arg)
+		case flux.ScopeQuery:
+			placed.Query = append(placed.Query, arg)
+		case flux.ScopeHeader:
+			placed.Header = append(placed.Header, arg)
+		case flux.ScopeCookie:
+			placed.Cookie = append(placed.Cookie, arg)
+		case flux.ScopeBody:
+			placed.Body = append(placed.Body, arg)
+		default:
+			return placed, fmt.Errorf("unsupported argument target scope: %s, argument: %s", arg.TargetScope, arg.Name)
+		}
+	}
+	return placed, nil
+}
+
+// AssembleHttpPath 使用参数值替换路径模板中的 {name} 变量，返回解码的Path和按路径段编码的RawPath
+func AssembleHttpPath(pathTemplate string, arguments []flux.Argument, ctx flux.Context) (path, rawPath string, err error) {
+	if !strings.Contains(pathTemplate, "{") {
+		return pathTemplate, "", nil
+	}
+	values, err := AssembleHttpValues(arguments, ctx)
+	if nil != err {
+		return "", "", err
+	}
+	var missing []string
+	replace := func(escape func(string) string) string {
+		return pathTemplatePattern.ReplaceAllStringFunc(pathTemplate, func(expr string) string {
+			name := expr[1 : len(expr)-1]
+			if vs, ok := values[name]; ok && len(vs) > 0 {
+				return escape(vs[0])
+			}
+			missing = append(missing, name)
+			return expr
+		})
+	}
+	path = replace(func(v string) string { return v })
+	if len(missing) > 0 {
+		return "", "", fmt.Errorf("path variables not resolved: %v, path: %s", missing, pathTemplate)
+	}
+	return path, replace(url.PathEscape), nil
+}
+
+// AssembleHttpBody 按编码格式封装参数为Body数据，返回Body数据和ContentType
+func AssembleHttpBody(encoding string, arguments []flux.Argument, ctx flux.Context) (io.Reader, string, error) {
+	switch strings.ToLower(encoding) {
+	case BodyEncodingJSON:
+		values, err := AssembleJSONValues(arguments, ctx)
+		if nil != err {
+			return nil, "", err
+		}
+		data, err := ext.JSONMarshal(values)
+		if nil != err {
+			return nil, "", fmt.Errorf("encode json body, err: %w", err)
+		}
+		return bytes.NewReader(data), flux.MIMEApplicationJSONCharsetUTF8, nil
+	case BodyEncodingMultipart:
+		values, err := AssembleJSONValues(arguments, ctx)
+		if nil != err {
+			return nil, "", err
+		}
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+		for _, arg := range arguments {
+			value, err := multipartValue(values[arg.Name])
+			if nil != err {
+				return nil, "", fmt.Errorf("encode multipart field: %s, err: %w", arg.Name, err)
+			}
+			if err := writer.WriteField(arg.Name, value); nil != err {
+				return nil, "", err
+			}
+		}
+		if err := writer.Close(); nil != err {
+			return nil, "", err
+		}
+		return buffer, writer.FormDataContentType(), nil
+	case BodyEncodingForm, "":
+		values, err := AssembleHttpValues(arguments, ctx)
+		if nil != err {
+			return nil, "", err
+		}
+		return strings.NewReader(values.Encode()), flux.MIMEApplicationForm, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported body encoding: %s", encoding)
+	}
+}
+
+// AssembleJSONValues 解析参数值为JSON对象；参数声明了子结构字段时，解析为嵌套的JSON对象
+func AssembleJSONValues(arguments []flux.Argument, ctx flux.Context) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(arguments))
+	for _, arg := range arguments {
+		if len(arg.Fields) > 0 && nil == arg.ValueLoader {
+			fields, err := AssembleJSONValues(arg.Fields, ctx)
+			if nil != err {
+				return nil, err
+			}
+			values[arg.Name] = fields
+			continue
+		}
+		if val, err := arg.Resolve(ctx); nil != err {
+			return nil, err
+		} else {
+			values[arg.Name] = val
+		}
+	}
+	return values, nil
+}
+
+func multipartValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		data, err := ext.JSONMarshal(v)
+		return string(data), err
+	default:
+		return cast.ToStringE(v)
+	}
+}
+
 func AssembleHttpValues(arguments []flux.Argument, ctx flux.Context) (url.Values, error) {
 	values := make(url.Values, len(arguments))
 	for _, arg := range arguments {
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// stdJsonSerializer 测试使用标准库JSON序列化，Map键按字母排序
+type stdJsonSerializer struct{}
+
+func (s *stdJsonSerializer) Marshal(any interface{}) ([]byte, error) {
+	return json.Marshal(any)
+}
+
+func (s *stdJsonSerializer) Unmarshal(data []byte, obj interface{}) error {
+	return json.Unmarshal(data, obj)
+}
+
+func TestAssembleHttpBody(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	ext.SetSerializer(ext.TypeNameSerializerJson, new(stdJsonSerializer))
+	user := ext.NewComplexArgument("net.bytepowered.test.UserVO", "user")
+	user.Fields = []flux.Argument{
+		ext.NewStringArgument("username"),
+		ext.NewIntegerArgument("year"),
+	}
+	arguments := []flux.Argument{ext.NewStringArgument("id"), user}
+	ctx := context.NewMockContext(map[string]interface{}{
+		"id":       "10086",
+		"username": "yongjia",
+		"year":     "2020",
+	})
+	cases := []struct {
+		encoding    string
+		arguments   []flux.Argument
+		contentType string
+		expected    string
+	}{
+		{encoding: BodyEncodingJSON, arguments: arguments, contentType: flux.MIMEApplicationJSONCharsetUTF8,
+			expected: `{"id":"10086","user":{"username":"yongjia","year":2020}}`},
+		{encoding: "", arguments: arguments[:1], contentType: flux.MIMEApplicationForm,
+			expected: "id=10086"},
+	}
+	for _, c := range cases {
+		body, ctype, err := AssembleHttpBody(c.encoding, c.arguments, ctx)
+		tester.NoError(err)
+		tester.Equal(c.contentType, ctype)
+		data, _ := ioutil.ReadAll(body)
+		tester.Equal(c.expected, string(data))
+	}
+	body, ctype, err := AssembleHttpBody(BodyEncodingMultipart, arguments, ctx)
+	tester.NoError(err)
+	tester.True(strings.HasPrefix(ctype, "multipart/form-data; boundary="))
+	data, _ := ioutil.ReadAll(body)
+	tester.Contains(string(data), `{"username":"yongjia","year":2020}`)
+	_, _, err = AssembleHttpBody("xml", arguments, ctx)
+	tester.Error(err)
+}
+
+func TestDefaultArgumentAssemble_Placement(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	withTarget := func(arg flux.Argument, scope string) flux.Argument {
+		arg.TargetScope = scope
+		return arg
+	}
+	service := &flux.BackendService{
+		Scheme:     "http",
+		RemoteHost: "127.0.0.1:8080",
+		Interface:  "/users/{id}/orders/{orderId}",
+		Method:     http.MethodPost,
+		Arguments: []flux.Argument{
+			ext.NewStringArgument("id"),
+			withTarget(ext.NewStringArgument("orderId"), flux.ScopePath),
+			withTarget(ext.NewStringArgument("page"), flux.ScopeQuery),
+			withTarget(ext.NewStringArgument("X-Tenant"), flux.ScopeHeader),
+			withTarget(ext.NewStringArgument("session"), flux.ScopeCookie),
+			ext.NewStringArgument("remark"),
+		},
+	}
+	ctx := context.NewMockContext(map[string]interface{}{
+		"id":       "a/b",
+		"orderId":  "1001",
+		"page":     "2",
+		"X-Tenant": "acme",
+		"session":  "s1",
+		"remark":   "hello",
+	})
+	req, err := DefaultArgumentAssemble(service, &url.URL{}, ioutil.NopCloser(strings.NewReader("")), ctx)
+	tester.NoError(err)
+	tester.Equal("http://127.0.0.1:8080/users/a%2Fb/orders/1001?page=2", req.URL.String())
+	tester.Equal("acme", req.Header.Get("X-Tenant"))
+	cookie, err := req.Cookie("session")
+	tester.NoError(err)
+	tester.Equal("s1", cookie.Value)
+	tester.Equal(flux.MIMEApplicationForm, req.Header.Get(flux.HeaderContentType))
+	body, _ := ioutil.ReadAll(req.Body)
+	tester.Equal("remark=hello", string(body))
+	// 路径变量缺失
+	service.Arguments = service.Arguments[1:]
+	_, err = DefaultArgumentAssemble(service, &url.URL{}, ioutil.NopCloser(strings.NewReader("")), ctx)
+	tester.Error(err)
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	fluxcontext "github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoInvokeCodecWithRetry(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	newService := func(method string, backoff string) flux.BackendService {
+		return flux.BackendService{
+			Scheme:     "http",
+			RemoteHost: strings.TrimPrefix(server.URL, "http://"),
+			Interface:  "/retry",
+			Method:     method,
+			EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoHttp},
+				{Name: flux.ServiceAttrTagRpcRetries, Value: "3"},
+				{Name: backend.ServiceAttrTagRetryBackoff, Value: backoff},
+			}},
+		}
+	}
+	newContext := func() flux.Context {
+		return fluxcontext.NewMockContext(map[string]interface{}{
+			"url":  &url.URL{},
+			"body": ioutil.NopCloser(strings.NewReader("")),
+		})
+	}
+	transport := NewBackendTransportService()
+	// 幂等请求：重试直到成功
+	resp, serr := backend.DoInvokeCodecWithRetry(newContext(), transport, newService(http.MethodGet, "1ms"))
+	tester.Nil(serr)
+	tester.Equal(http.StatusOK, resp.StatusCode)
+	tester.Equal(int32(3), atomic.LoadInt32(&hits))
+	// 非幂等请求：不重试
+	atomic.StoreInt32(&hits, 0)
+	resp, serr = backend.DoInvokeCodecWithRetry(newContext(), transport, newService(http.MethodPost, "1ms"))
+	tester.Nil(serr)
+	tester.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	tester.Equal(int32(1), atomic.LoadInt32(&hits))
+	// 剩余时间不足以等待退避：不重试
+	atomic.StoreInt32(&hits, 0)
+	ctx := newContext()
+	goctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ctx.SetContext(goctx)
+	resp, serr = backend.DoInvokeCodecWithRetry(ctx, transport, newService(http.MethodGet, "1s"))
+	tester.Nil(serr)
+	tester.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	tester.Equal(int32(1), atomic.LoadInt32(&hits))
+}
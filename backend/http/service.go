@@ -1,14 +1,18 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/bytepowered/flux"
 	"github.com/bytepowered/flux/backend"
 	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
 	"github.com/spf13/cast"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,6 +22,15 @@ func init() {
 
 var _ flux.BackendTransport = new(BackendTransportService)
 
+const (
+	// ConfigKeyTimeout 请求没有截止时间，且服务没有声明rpc-timeout时，使用的默认超时时间
+	ConfigKeyTimeout = "timeout"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+)
+
 type (
 	// Option 配置函数
 	Option func(service *BackendTransportService)
@@ -27,25 +40,33 @@ type (
 
 type BackendTransportService struct {
 	httpClient        *http.Client
+	timeout           time.Duration
 	responseCodecFunc flux.BackendResponseCodecFunc
 	argAssembleFunc   ArgumentsAssembleFunc
+	upstreams         *Upstreams
+	stopHealthCheck   chan struct{}
+	stopOnce          sync.Once
 }
 
 func NewBackendTransportService() *BackendTransportService {
 	return &BackendTransportService{
-		httpClient: &http.Client{
-			Timeout: time.Second * 10,
-		},
+		httpClient:        &http.Client{},
+		timeout:           defaultTimeout,
 		responseCodecFunc: NewBackendResponseCodecFunc(),
+		argAssembleFunc:   DefaultArgumentAssemble,
+		upstreams:         NewUpstreams(),
+		stopHealthCheck:   make(chan struct{}),
 	}
 }
 
 func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
 	bts := &BackendTransportService{
-		httpClient: &http.Client{
-			Timeout: time.Second * 10,
-		},
+		httpClient:        &http.Client{},
+		timeout:           defaultTimeout,
 		responseCodecFunc: NewBackendResponseCodecFunc(),
+		argAssembleFunc:   DefaultArgumentAssemble,
+		upstreams:         NewUpstreams(),
+		stopHealthCheck:   make(chan struct{}),
 	}
 	for _, opt := range opts {
 		opt(bts)
@@ -74,6 +95,42 @@ func WithArgumentAssembleFunc(fun ArgumentsAssembleFunc) Option {
 	}
 }
 
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("Http backend transport initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyTimeout: defaultTimeout.String(),
+	})
+	b.timeout = config.GetDuration(ConfigKeyTimeout)
+	for name := range config.GetStringMap(ConfigKeyUpstreams) {
+		up, err := NewUpstreamOfConfig(name, config.Sub(ConfigKeyUpstreams+"."+name))
+		if nil != err {
+			return err
+		}
+		logger.Infow("Http backend upstream loaded", "upstream", name, "balancer", up.Balancer, "targets", len(up.Targets))
+		b.upstreams.Add(up)
+	}
+	ext.SetInspectFunc(InspectNameUpstreams, func() interface{} {
+		return b.upstreams.States()
+	})
+	return nil
+}
+
+// Startup 启动命名Upstream的主动健康检查；RemoteHost声明多个地址的Upstream只使用被动异常摘除
+func (b *BackendTransportService) Startup() error {
+	for _, up := range b.upstreams.named {
+		up.StartHealthCheck(b.httpClient, b.stopHealthCheck)
+	}
+	return nil
+}
+
+// Shutdown 停止Upstream的主动健康检查；可重复调用
+func (b *BackendTransportService) Shutdown(_ context.Context) error {
+	b.stopOnce.Do(func() {
+		close(b.stopHealthCheck)
+	})
+	return nil
+}
+
 func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
 	return b.responseCodecFunc
 }
@@ -102,6 +159,41 @@ func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.Bac
 }
 
 func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	upstream, err := b.upstreams.Lookup(service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageHttpAssembleFailed,
+			Internal:   err,
+		}
+	}
+	if nil == upstream {
+		return b.DoInvoke(ctx, service)
+	}
+	// 多目标地址：按负载均衡策略选择目标地址
+	target, err := upstream.Select(ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: http.StatusServiceUnavailable,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageHttpNoAvailableTarget,
+			Internal:   fmt.Errorf("upstream: %s, err: %w", upstream.Name, err),
+		}
+	}
+	service.RemoteHost = target.Address
+	upstream.Acquire(target)
+	ret, serr := b.DoInvoke(ctx, service)
+	failed := nil != serr
+	if resp, ok := ret.(*http.Response); ok && resp.StatusCode >= http.StatusInternalServerError {
+		failed = true
+	}
+	upstream.Release(target, failed)
+	return ret, serr
+}
+
+// DoInvoke 执行单个目标地址的Http请求
+func (b *BackendTransportService) DoInvoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
 	body, _ := ctx.Request().BodyReader()
 	newRequest, err := b.argAssembleFunc(&service, ctx.Request().URL(), body, ctx)
 	if nil != err {
@@ -115,14 +207,55 @@ func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendS
 	return b.ExecuteRequest(newRequest, service, ctx)
 }
 
-func (b *BackendTransportService) ExecuteRequest(newRequest *http.Request, _ flux.BackendService, ctx flux.Context) (interface{}, *flux.ServeError) {
-	// Header透传以及传递AttrValues
-	newRequest.Header = ctx.Request().HeaderVars()
+func (b *BackendTransportService) ExecuteRequest(newRequest *http.Request, service flux.BackendService, ctx flux.Context) (interface{}, *flux.ServeError) {
+	return b.ExecuteRequestExcludes(newRequest, service, ctx)
+}
+
+// ExecuteRequestExcludes 执行Http请求；excludes 为不透传的原请求Header
+func (b *BackendTransportService) ExecuteRequestExcludes(newRequest *http.Request, service flux.BackendService, ctx flux.Context, excludes ...string) (interface{}, *flux.ServeError) {
+	// Header透传以及传递AttrValues；封装参数时设置的Header优先，Cookie与原请求合并
+	assembled := newRequest.Header
+	newRequest.Header = ctx.Request().HeaderVars().Clone()
+	if nil == newRequest.Header {
+		newRequest.Header = make(http.Header)
+	}
+	for _, name := range excludes {
+		newRequest.Header.Del(name)
+	}
+	for k, vs := range assembled {
+		if cookie := newRequest.Header.Get(flux.HeaderCookie); flux.HeaderCookie == k && "" != cookie {
+			newRequest.Header.Set(k, cookie+"; "+strings.Join(vs, "; "))
+		} else {
+			newRequest.Header[k] = vs
+		}
+	}
 	for k, v := range ctx.Attributes() {
 		newRequest.Header.Set(k, cast.ToString(v))
 	}
+	if IsEventStreamService(service) {
+		return b.ExecuteEventStream(newRequest, service, ctx)
+	}
+	// 服务声明的rpc-timeout，只能缩短请求剩余的截止时间；两者都没有时，使用默认超时时间
+	var timeout time.Duration
+	if to := service.AttrRpcTimeout(); "" != to {
+		if d, err := time.ParseDuration(to); nil == err {
+			timeout = d
+		} else {
+			logger.WithContext(ctx).Warnw("Illegal service rpc-timeout", "timeout", to)
+		}
+	}
+	if _, ok := newRequest.Context().Deadline(); !ok && timeout <= 0 {
+		timeout = b.timeout
+	}
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		var goctx context.Context
+		goctx, cancel = context.WithTimeout(newRequest.Context(), timeout)
+		newRequest = newRequest.WithContext(goctx)
+	}
 	resp, err := b.httpClient.Do(newRequest)
 	if nil != err {
+		cancel()
 		msg := flux.ErrorMessageHttpInvokeFailed
 		if uErr, ok := err.(*url.Error); ok {
 			msg = fmt.Sprintf("HTTPEX:REMOTE_ERROR:%s", uErr.Error())
@@ -134,5 +267,17 @@ func (b *BackendTransportService) ExecuteRequest(newRequest *http.Request, _ flu
 			Internal:   err,
 		}
 	}
+	// 响应数据读取完成后，释放rpc-timeout的Context
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
 	return resp, nil
 }
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
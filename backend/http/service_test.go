@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	fluxcontext "github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackendTransportService_DefaultTimeout(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+			_, _ = w.Write([]byte("ok"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	newService := func(rpcTimeout string) flux.BackendService {
+		attrs := []flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoHttp}}
+		if "" != rpcTimeout {
+			attrs = append(attrs, flux.Attribute{Name: flux.ServiceAttrTagRpcTimeout, Value: rpcTimeout})
+		}
+		return flux.BackendService{
+			Scheme:             "http",
+			RemoteHost:         strings.TrimPrefix(server.URL, "http://"),
+			Interface:          "/slow",
+			Method:             http.MethodGet,
+			EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+		}
+	}
+	newContext := func() flux.Context {
+		return fluxcontext.NewMockContext(map[string]interface{}{
+			"url":  &url.URL{},
+			"body": ioutil.NopCloser(strings.NewReader("")),
+		})
+	}
+	transport := NewBackendTransportService()
+	tester.NoError(transport.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyTimeout: "100ms",
+	})))
+	// 请求没有截止时间，服务没有声明rpc-timeout：使用默认超时时间
+	_, serr := transport.Invoke(newContext(), newService(""))
+	tester.NotNil(serr)
+	// 服务声明的rpc-timeout优先
+	resp, serr := transport.Invoke(newContext(), newService("1s"))
+	tester.Nil(serr)
+	tester.Equal(http.StatusOK, resp.(*http.Response).StatusCode)
+	_ = resp.(*http.Response).Body.Close()
+	// 请求的截止时间优先
+	ctx := newContext()
+	goctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx.SetContext(goctx)
+	resp, serr = transport.Invoke(ctx, newService(""))
+	tester.Nil(serr)
+	_ = resp.(*http.Response).Body.Close()
+}
+
+func TestBackendTransportService_Shutdown(t *testing.T) {
+	tester := assert.New(t)
+	transport := NewBackendTransportService()
+	tester.NoError(transport.Startup())
+	tester.NoError(transport.Shutdown(context.Background()))
+	tester.NotPanics(func() {
+		_ = transport.Shutdown(context.Background())
+	})
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// ServiceAttrTagEventStream 声明后端服务为Server-Sent Events流式服务：[true, false]；默认为false
+	ServiceAttrTagEventStream = "eventstream"
+)
+
+const (
+	// QueryKeyLastEventID 不支持自定义Header的客户端，通过Query参数传递Last-Event-ID
+	QueryKeyLastEventID   = "lastEventId"
+	HeaderXAccelBuffering = "X-Accel-Buffering"
+)
+
+// IsEventStreamService 判断后端服务是否声明为SSE流式服务
+func IsEventStreamService(service flux.BackendService) bool {
+	return service.GetAttr(ServiceAttrTagEventStream).GetBool()
+}
+
+// IsEventStreamResponse 判断后端服务是否返回SSE事件流
+func IsEventStreamResponse(resp *http.Response) bool {
+	return nil != resp && resp.StatusCode == http.StatusOK &&
+		flux.MIMETextEventStream == mediaTypeOf(resp.Header.Get(flux.HeaderContentType))
+}
+
+// ExecuteEventStream 执行SSE请求，保持与后端服务的连接并返回事件流。
+// 请求截止时间和rpc-timeout只约束等待响应Header的时间；事件流的生命周期跟随客户端连接，
+// 客户端断开连接或响应数据流被关闭时，关闭后端服务连接。
+func (b *BackendTransportService) ExecuteEventStream(newRequest *http.Request, service flux.BackendService, ctx flux.Context) (interface{}, *flux.ServeError) {
+	newRequest.Header.Set(flux.HeaderAccept, flux.MIMETextEventStream)
+	newRequest.Header.Set(flux.HeaderCacheControl, "no-cache")
+	// 断线重连：EventSource通过Header传递Last-Event-ID，不支持自定义Header的客户端通过Query参数传递
+	if "" == newRequest.Header.Get(flux.HeaderLastEventID) {
+		if inURL := ctx.Request().URL(); nil != inURL {
+			if id := inURL.Query().Get(QueryKeyLastEventID); "" != id {
+				newRequest.Header.Set(flux.HeaderLastEventID, id)
+			}
+		}
+	}
+	streamCtx, cancel := context.WithCancel(ctx.Request().Context())
+	waitCtx, waitCancel := ctx.Context(), context.CancelFunc(func() {})
+	if to := service.AttrRpcTimeout(); "" != to {
+		if timeout, err := time.ParseDuration(to); nil == err {
+			waitCtx, waitCancel = context.WithTimeout(waitCtx, timeout)
+		} else {
+			logger.WithContext(ctx).Warnw("Illegal service rpc-timeout", "timeout", to)
+		}
+	}
+	// 等待响应Header期间，截止时间到达则中断请求
+	stop, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-waitCtx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+	resp, err := b.httpClient.Do(newRequest.WithContext(streamCtx))
+	close(stop)
+	<-exited
+	waitCancel()
+	if nil == err && nil != streamCtx.Err() {
+		_ = resp.Body.Close()
+		err = streamCtx.Err()
+	}
+	if nil != err {
+		cancel()
+		msg := flux.ErrorMessageHttpInvokeFailed
+		if uErr, ok := err.(*url.Error); ok {
+			msg = fmt.Sprintf("HTTPEX:REMOTE_ERROR:%s", uErr.Error())
+		}
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    msg,
+			Internal:   err,
+		}
+	}
+	if IsEventStreamResponse(resp) {
+		// 禁止反向代理缓冲事件流
+		resp.Header.Set(flux.HeaderCacheControl, "no-cache")
+		resp.Header.Set(HeaderXAccelBuffering, "no")
+		resp.Header.Del(flux.HeaderContentLength)
+	}
+	// 响应数据流被关闭时，关闭后端服务连接
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func mediaTypeOf(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); nil == err {
+		return mediaType
+	}
+	return contentType
+}
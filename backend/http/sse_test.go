@@ -0,0 +1,83 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	fluxcontext "github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackendTransportService_EventStream(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	lastEventIds := make(chan string, 1)
+	closed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventIds <- r.Header.Get(flux.HeaderLastEventID)
+		w.Header().Set(flux.HeaderContentType, flux.MIMETextEventStream)
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 2; i++ {
+			_, _ = io.WriteString(w, "id: 1\ndata: tick\n\n")
+			w.(http.Flusher).Flush()
+		}
+		// 保持连接，直到网关关闭后端连接
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer server.Close()
+	service := flux.BackendService{
+		Scheme:     "http",
+		RemoteHost: strings.TrimPrefix(server.URL, "http://"),
+		Interface:  "/events",
+		Method:     http.MethodGet,
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: ServiceAttrTagEventStream, Value: true},
+		}},
+	}
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	defer disconnect()
+	ctx := fluxcontext.NewMockContext(map[string]interface{}{
+		"url":     &url.URL{RawQuery: "lastEventId=42"},
+		"body":    ioutil.NopCloser(strings.NewReader("")),
+		"context": clientCtx,
+	})
+	// 请求截止时间只约束等待响应Header的时间
+	deadline, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ctx.SetContext(deadline)
+	transport := NewBackendTransportService()
+	resp, serr := transport.InvokeCodec(ctx, service)
+	tester.Nil(serr)
+	tester.Equal("42", <-lastEventIds)
+	tester.Equal(flux.MIMETextEventStream, resp.Headers.Get(flux.HeaderContentType))
+	<-deadline.Done()
+	reader := bufio.NewReader(resp.Body.(io.Reader))
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+		tester.NoError(err)
+		tester.Equal("id: 1\n", line)
+		_, _ = reader.ReadString('\n')
+		_, _ = reader.ReadString('\n')
+	}
+	// 客户端断开连接，关闭后端连接
+	disconnect()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		tester.Fail("upstream not closed after client disconnected")
+	}
+	_ = resp.Body.(io.Closer).Close()
+}
@@ -0,0 +1,434 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 负载均衡策略
+const (
+	BalancerRoundRobin     = "round-robin"
+	BalancerLeastRequest   = "least-request"
+	BalancerConsistentHash = "consistent-hash"
+)
+
+// Upstream配置：backend_transports.HTTP.upstreams.{name}
+const (
+	ConfigKeyUpstreams = "upstreams"
+
+	upstreamConfigKeyTargets  = "targets"
+	upstreamConfigKeyBalancer = "balancer"
+	upstreamConfigKeyHashKey  = "hash_key"
+
+	upstreamConfigKeyHealthCheck        = "health_check"
+	upstreamConfigKeyCheckPath          = "path"
+	upstreamConfigKeyCheckScheme        = "scheme"
+	upstreamConfigKeyCheckInterval      = "interval"
+	upstreamConfigKeyCheckTimeout       = "timeout"
+	upstreamConfigKeyHealthyThreshold   = "healthy_threshold"
+	upstreamConfigKeyUnhealthyThreshold = "unhealthy_threshold"
+
+	upstreamConfigKeyOutlier             = "outlier"
+	upstreamConfigKeyConsecutiveFailures = "consecutive_failures"
+	upstreamConfigKeyEjectionTime        = "ejection_time"
+)
+
+// Service属性：RemoteHost声明多个地址时的负载均衡配置
+const (
+	// ServiceAttrTagLoadBalance 负载均衡策略：[round-robin, least-request, consistent-hash]
+	ServiceAttrTagLoadBalance = "loadbalance"
+	// ServiceAttrTagHashKey 一致性Hash的Lookup表达式，例如：header:X-User-Id
+	ServiceAttrTagHashKey = "hashkey"
+)
+
+const (
+	// InspectNameUpstreams Admin服务查询Upstream状态的名称
+	InspectNameUpstreams = "http-upstreams"
+	// 一致性Hash环上，每个目标地址的虚拟节点数
+	hashVirtualNodes = 160
+	// 默认的Hash Key：请求端地址
+	defaultHashKey = "request:address"
+)
+
+var (
+	ErrNoAvailableTarget = errors.New("no available upstream target")
+)
+
+type (
+	// HealthCheck 主动健康检查配置；Path为空时，不执行主动健康检查
+	HealthCheck struct {
+		Path               string
+		Scheme             string
+		Interval           time.Duration
+		Timeout            time.Duration
+		HealthyThreshold   int
+		UnhealthyThreshold int
+	}
+	// Outlier 被动异常摘除配置；ConsecutiveFailures为0时，不执行异常摘除
+	Outlier struct {
+		ConsecutiveFailures int32
+		EjectionTime        time.Duration
+	}
+	// Target 上游目标地址及其状态
+	Target struct {
+		Address      string
+		unhealthy    int32
+		active       int64
+		failures     int32
+		ejectedUntil int64
+		// 主动健康检查的连续计数，只在检查协程中访问
+		checkSuccess int
+		checkFailure int
+	}
+	// TargetState 目标地址的状态快照
+	TargetState struct {
+		Address  string `json:"address"`
+		Healthy  bool   `json:"healthy"`
+		Ejected  bool   `json:"ejected"`
+		Active   int64  `json:"active"`
+		Failures int32  `json:"failures"`
+	}
+	// UpstreamState 上游服务的状态快照
+	UpstreamState struct {
+		Name     string        `json:"name"`
+		Balancer string        `json:"balancer"`
+		Targets  []TargetState `json:"targets"`
+	}
+	hashNode struct {
+		hash   uint32
+		target *Target
+	}
+)
+
+// Upstream 多个目标地址组成的上游服务，按负载均衡策略选择目标地址
+type Upstream struct {
+	Name        string
+	Targets     []*Target
+	Balancer    string
+	HashKey     string
+	HealthCheck HealthCheck
+	Outlier     Outlier
+	counter     uint64
+	ring        []hashNode
+}
+
+func NewUpstream(name string, addresses []string, balancer, hashKey string) (*Upstream, error) {
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("upstream targets is empty, upstream: %s", name)
+	}
+	if "" == balancer {
+		balancer = BalancerRoundRobin
+	}
+	switch balancer {
+	case BalancerRoundRobin, BalancerLeastRequest, BalancerConsistentHash:
+	default:
+		return nil, fmt.Errorf("unsupported upstream balancer: %s, upstream: %s", balancer, name)
+	}
+	if "" == hashKey {
+		hashKey = defaultHashKey
+	}
+	up := &Upstream{
+		Name:     name,
+		Targets:  make([]*Target, 0, len(addresses)),
+		Balancer: balancer,
+		HashKey:  hashKey,
+		HealthCheck: HealthCheck{
+			Scheme:             "http",
+			Interval:           time.Second * 10,
+			Timeout:            time.Second * 2,
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 3,
+		},
+		Outlier: Outlier{
+			ConsecutiveFailures: 5,
+			EjectionTime:        time.Second * 30,
+		},
+	}
+	for _, addr := range addresses {
+		if addr = strings.TrimSpace(addr); "" != addr {
+			up.Targets = append(up.Targets, &Target{Address: addr})
+		}
+	}
+	if len(up.Targets) == 0 {
+		return nil, fmt.Errorf("upstream targets is empty, upstream: %s", name)
+	}
+	if BalancerConsistentHash == balancer {
+		up.ring = make([]hashNode, 0, len(up.Targets)*hashVirtualNodes)
+		for _, t := range up.Targets {
+			for i := 0; i < hashVirtualNodes; i++ {
+				up.ring = append(up.ring, hashNode{hash: hashOf(t.Address + "#" + strconv.Itoa(i)), target: t})
+			}
+		}
+		sort.Slice(up.ring, func(i, j int) bool {
+			return up.ring[i].hash < up.ring[j].hash
+		})
+	}
+	return up, nil
+}
+
+// NewUpstreamOfConfig 从配置中创建Upstream
+func NewUpstreamOfConfig(name string, config *flux.Configuration) (*Upstream, error) {
+	up, err := NewUpstream(name, config.GetStringSlice(upstreamConfigKeyTargets),
+		strings.ToLower(config.GetString(upstreamConfigKeyBalancer)), config.GetString(upstreamConfigKeyHashKey))
+	if nil != err {
+		return nil, err
+	}
+	if config.IsSet(upstreamConfigKeyHealthCheck) {
+		check := config.Sub(upstreamConfigKeyHealthCheck)
+		check.SetDefaults(map[string]interface{}{
+			upstreamConfigKeyCheckScheme:        up.HealthCheck.Scheme,
+			upstreamConfigKeyCheckInterval:      up.HealthCheck.Interval,
+			upstreamConfigKeyCheckTimeout:       up.HealthCheck.Timeout,
+			upstreamConfigKeyHealthyThreshold:   up.HealthCheck.HealthyThreshold,
+			upstreamConfigKeyUnhealthyThreshold: up.HealthCheck.UnhealthyThreshold,
+		})
+		up.HealthCheck = HealthCheck{
+			Path:               check.GetString(upstreamConfigKeyCheckPath),
+			Scheme:             check.GetString(upstreamConfigKeyCheckScheme),
+			Interval:           check.GetDuration(upstreamConfigKeyCheckInterval),
+			Timeout:            check.GetDuration(upstreamConfigKeyCheckTimeout),
+			HealthyThreshold:   check.GetInt(upstreamConfigKeyHealthyThreshold),
+			UnhealthyThreshold: check.GetInt(upstreamConfigKeyUnhealthyThreshold),
+		}
+	}
+	if config.IsSet(upstreamConfigKeyOutlier) {
+		outlier := config.Sub(upstreamConfigKeyOutlier)
+		outlier.SetDefaults(map[string]interface{}{
+			upstreamConfigKeyConsecutiveFailures: up.Outlier.ConsecutiveFailures,
+			upstreamConfigKeyEjectionTime:        up.Outlier.EjectionTime,
+		})
+		up.Outlier = Outlier{
+			ConsecutiveFailures: outlier.GetInt32(upstreamConfigKeyConsecutiveFailures),
+			EjectionTime:        outlier.GetDuration(upstreamConfigKeyEjectionTime),
+		}
+	}
+	return up, nil
+}
+
+// Select 按负载均衡策略，选择一个可用的目标地址；全部目标地址都不可用时，从全部目标地址中选择
+func (u *Upstream) Select(ctx flux.Context) (*Target, error) {
+	now := time.Now().UnixNano()
+	if BalancerConsistentHash == u.Balancer {
+		return u.selectByHash(ctx, now)
+	}
+	available := make([]*Target, 0, len(u.Targets))
+	for _, t := range u.Targets {
+		if t.isAvailable(now) {
+			available = append(available, t)
+		}
+	}
+	if len(available) == 0 {
+		if len(u.Targets) == 0 {
+			return nil, ErrNoAvailableTarget
+		}
+		// 恐慌阈值：全部目标地址都不可用时，回退到全部目标地址，避免摘除导致上游服务完全不可用
+		available = u.Targets
+	}
+	offset := int(atomic.AddUint64(&u.counter, 1) % uint64(len(available)))
+	if BalancerLeastRequest != u.Balancer {
+		return available[offset], nil
+	}
+	// 从轮询位置开始查找，活跃请求数相同时分散选择
+	selected := available[offset]
+	for i := 1; i < len(available); i++ {
+		t := available[(offset+i)%len(available)]
+		if atomic.LoadInt64(&t.active) < atomic.LoadInt64(&selected.active) {
+			selected = t
+		}
+	}
+	return selected, nil
+}
+
+func (u *Upstream) selectByHash(ctx flux.Context, now int64) (*Target, error) {
+	key := ""
+	if scope, name, ok := pkg.LookupParseExpr(u.HashKey); ok {
+		if mtv, err := ext.GetArgumentLookupFunc()(scope, name, ctx); nil == err {
+			key, _ = backend.CastDecodeMTValueToString(mtv)
+		}
+	}
+	hash := hashOf(key)
+	start := sort.Search(len(u.ring), func(i int) bool {
+		return u.ring[i].hash >= hash
+	})
+	// 顺时针查找第一个可用的目标地址
+	for i := 0; i < len(u.ring); i++ {
+		if t := u.ring[(start+i)%len(u.ring)].target; t.isAvailable(now) {
+			return t, nil
+		}
+	}
+	if len(u.ring) == 0 {
+		return nil, ErrNoAvailableTarget
+	}
+	// 恐慌阈值：全部目标地址都不可用时，忽略目标地址的状态
+	return u.ring[start%len(u.ring)].target, nil
+}
+
+// Acquire 记录目标地址的活跃请求
+func (u *Upstream) Acquire(t *Target) {
+	atomic.AddInt64(&t.active, 1)
+}
+
+// Release 释放目标地址的活跃请求，并记录调用结果；连续失败达到阈值时，摘除目标地址
+func (u *Upstream) Release(t *Target, failed bool) {
+	atomic.AddInt64(&t.active, -1)
+	if !failed {
+		atomic.StoreInt32(&t.failures, 0)
+		return
+	}
+	if u.Outlier.ConsecutiveFailures <= 0 {
+		return
+	}
+	if atomic.AddInt32(&t.failures, 1) >= u.Outlier.ConsecutiveFailures {
+		atomic.StoreInt32(&t.failures, 0)
+		atomic.StoreInt64(&t.ejectedUntil, time.Now().Add(u.Outlier.EjectionTime).UnixNano())
+		logger.Warnw("BACKEND:HTTP:UPSTREAM:EJECT",
+			"upstream", u.Name, "target", t.Address, "ejection-time", u.Outlier.EjectionTime.String())
+	}
+}
+
+// State 返回上游服务的状态快照
+func (u *Upstream) State() UpstreamState {
+	now := time.Now().UnixNano()
+	states := make([]TargetState, len(u.Targets))
+	for i, t := range u.Targets {
+		states[i] = TargetState{
+			Address:  t.Address,
+			Healthy:  atomic.LoadInt32(&t.unhealthy) == 0,
+			Ejected:  now < atomic.LoadInt64(&t.ejectedUntil),
+			Active:   atomic.LoadInt64(&t.active),
+			Failures: atomic.LoadInt32(&t.failures),
+		}
+	}
+	return UpstreamState{Name: u.Name, Balancer: u.Balancer, Targets: states}
+}
+
+// StartHealthCheck 启动主动健康检查，直到stop被关闭
+func (u *Upstream) StartHealthCheck(client *http.Client, stop <-chan struct{}) {
+	if "" == u.HealthCheck.Path || u.HealthCheck.Interval <= 0 {
+		return
+	}
+	logger.Infow("BACKEND:HTTP:UPSTREAM:HEALTH_CHECK:START", "upstream", u.Name, "path", u.HealthCheck.Path)
+	go func() {
+		ticker := time.NewTicker(u.HealthCheck.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for _, t := range u.Targets {
+					u.check(client, t)
+				}
+			}
+		}
+	}()
+}
+
+func (u *Upstream) check(client *http.Client, t *Target) {
+	ctx, cancel := context.WithTimeout(context.Background(), u.HealthCheck.Timeout)
+	defer cancel()
+	healthy := false
+	url := u.HealthCheck.Scheme + "://" + t.Address + u.HealthCheck.Path
+	if req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil); nil == err {
+		if resp, err := client.Do(req); nil == err {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+			healthy = resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+		}
+	}
+	if healthy {
+		t.checkFailure = 0
+		t.checkSuccess++
+		if t.checkSuccess >= u.HealthCheck.HealthyThreshold && atomic.CompareAndSwapInt32(&t.unhealthy, 1, 0) {
+			logger.Infow("BACKEND:HTTP:UPSTREAM:HEALTHY", "upstream", u.Name, "target", t.Address)
+		}
+	} else {
+		t.checkSuccess = 0
+		t.checkFailure++
+		if t.checkFailure >= u.HealthCheck.UnhealthyThreshold && atomic.CompareAndSwapInt32(&t.unhealthy, 0, 1) {
+			logger.Warnw("BACKEND:HTTP:UPSTREAM:UNHEALTHY", "upstream", u.Name, "target", t.Address)
+		}
+	}
+}
+
+func (t *Target) isAvailable(now int64) bool {
+	return atomic.LoadInt32(&t.unhealthy) == 0 && now >= atomic.LoadInt64(&t.ejectedUntil)
+}
+
+// Upstreams 上游服务注册表：配置声明的命名Upstream，以及RemoteHost声明多个地址的Upstream
+type Upstreams struct {
+	named  map[string]*Upstream
+	adhocs sync.Map // remoteHost|balancer|hashKey -> *Upstream
+}
+
+func NewUpstreams() *Upstreams {
+	return &Upstreams{named: make(map[string]*Upstream)}
+}
+
+// Lookup 查找服务的Upstream；RemoteHost为单个地址时，返回nil。
+// RemoteHost声明多个地址的Upstream，按地址和负载均衡配置缓存；其不执行主动健康检查，只通过被动异常摘除剔除故障地址。
+func (us *Upstreams) Lookup(service flux.BackendService) (*Upstream, error) {
+	host := service.RemoteHost
+	// 配置Key不区分大小写
+	if up, ok := us.named[strings.ToLower(host)]; ok {
+		return up, nil
+	}
+	if !strings.Contains(host, ",") {
+		return nil, nil
+	}
+	balancer := strings.ToLower(service.GetAttr(ServiceAttrTagLoadBalance).GetString())
+	hashKey := service.GetAttr(ServiceAttrTagHashKey).GetString()
+	key := host + "|" + balancer + "|" + hashKey
+	if up, ok := us.adhocs.Load(key); ok {
+		return up.(*Upstream), nil
+	}
+	up, err := NewUpstream(host, strings.Split(host, ","), balancer, hashKey)
+	if nil != err {
+		return nil, err
+	}
+	actual, _ := us.adhocs.LoadOrStore(key, up)
+	return actual.(*Upstream), nil
+}
+
+// Add 添加命名Upstream
+func (us *Upstreams) Add(up *Upstream) {
+	us.named[strings.ToLower(up.Name)] = up
+}
+
+// States 返回全部Upstream的状态快照
+func (us *Upstreams) States() []UpstreamState {
+	out := make([]UpstreamState, 0, len(us.named))
+	for _, up := range us.named {
+		out = append(out, up.State())
+	}
+	us.adhocs.Range(func(_, v interface{}) bool {
+		out = append(out, v.(*Upstream).State())
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func hashOf(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
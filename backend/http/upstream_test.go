@@ -0,0 +1,127 @@
+package http
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUpstream_SelectRoundRobin(t *testing.T) {
+	tester := assert.New(t)
+	up, err := NewUpstream("test", []string{"a:80", "b:80", "c:80"}, BalancerRoundRobin, "")
+	tester.NoError(err)
+	ctx := context.NewEmptyContext()
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		target, err := up.Select(ctx)
+		tester.NoError(err)
+		counts[target.Address]++
+	}
+	tester.Equal(map[string]int{"a:80": 10, "b:80": 10, "c:80": 10}, counts)
+}
+
+func TestUpstream_SelectLeastRequest(t *testing.T) {
+	tester := assert.New(t)
+	up, err := NewUpstream("test", []string{"a:80", "b:80"}, BalancerLeastRequest, "")
+	tester.NoError(err)
+	ctx := context.NewEmptyContext()
+	busy, _ := up.Select(ctx)
+	up.Acquire(busy)
+	for i := 0; i < 5; i++ {
+		target, err := up.Select(ctx)
+		tester.NoError(err)
+		tester.NotEqual(busy.Address, target.Address)
+	}
+}
+
+func TestUpstream_SelectConsistentHash(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	up, err := NewUpstream("test", []string{"a:80", "b:80", "c:80"}, BalancerConsistentHash, "query:uid")
+	tester.NoError(err)
+	ctx := context.NewMockContext(map[string]interface{}{"uid": "10086"})
+	first, err := up.Select(ctx)
+	tester.NoError(err)
+	for i := 0; i < 10; i++ {
+		target, _ := up.Select(ctx)
+		tester.Equal(first.Address, target.Address)
+	}
+}
+
+func TestUpstream_OutlierEjection(t *testing.T) {
+	tester := assert.New(t)
+	up, err := NewUpstream("test", []string{"a:80"}, BalancerRoundRobin, "")
+	tester.NoError(err)
+	ctx := context.NewEmptyContext()
+	for i := int32(0); i < up.Outlier.ConsecutiveFailures; i++ {
+		target, err := up.Select(ctx)
+		tester.NoError(err)
+		up.Acquire(target)
+		up.Release(target, true)
+	}
+	// 全部目标地址被摘除：回退到全部目标地址
+	target, err := up.Select(ctx)
+	tester.NoError(err)
+	tester.Equal("a:80", target.Address)
+	tester.True(up.State().Targets[0].Ejected)
+	// 部分目标地址被摘除：只选择可用的目标地址
+	up, err = NewUpstream("test", []string{"a:80", "b:80"}, BalancerRoundRobin, "")
+	tester.NoError(err)
+	eject := func(address string) {
+		for _, target := range up.Targets {
+			if target.Address == address {
+				for i := int32(0); i < up.Outlier.ConsecutiveFailures; i++ {
+					up.Acquire(target)
+					up.Release(target, true)
+				}
+			}
+		}
+	}
+	eject("a:80")
+	for i := 0; i < 4; i++ {
+		target, err := up.Select(ctx)
+		tester.NoError(err)
+		tester.Equal("b:80", target.Address)
+	}
+	eject("b:80")
+	selected := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		target, err := up.Select(ctx)
+		tester.NoError(err)
+		selected[target.Address] = true
+	}
+	tester.Equal(map[string]bool{"a:80": true, "b:80": true}, selected)
+}
+
+func TestUpstreams_Lookup(t *testing.T) {
+	tester := assert.New(t)
+	us := NewUpstreams()
+	up, err := us.Lookup(flux.BackendService{RemoteHost: "a:80"})
+	tester.NoError(err)
+	tester.Nil(up)
+	up, err = us.Lookup(flux.BackendService{RemoteHost: "a:80,b:80"})
+	tester.NoError(err)
+	tester.Equal(2, len(up.Targets))
+	// 相同地址、不同负载均衡配置的服务，使用各自的Upstream
+	hashed, err := us.Lookup(flux.BackendService{RemoteHost: "a:80,b:80",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: ServiceAttrTagLoadBalance, Value: BalancerConsistentHash},
+			{Name: ServiceAttrTagHashKey, Value: "header:X-User-Id"},
+		}},
+	})
+	tester.NoError(err)
+	tester.NotEqual(up, hashed)
+	tester.Equal(BalancerConsistentHash, hashed.Balancer)
+	tester.Equal("header:X-User-Id", hashed.HashKey)
+	again, err := us.Lookup(flux.BackendService{RemoteHost: "a:80,b:80"})
+	tester.NoError(err)
+	tester.True(up == again)
+	named, _ := NewUpstream("User-Service", []string{"c:80"}, "", "")
+	us.Add(named)
+	up, err = us.Lookup(flux.BackendService{RemoteHost: "user-service"})
+	tester.NoError(err)
+	tester.Equal(named, up)
+}
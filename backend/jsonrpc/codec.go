@@ -0,0 +1,137 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	backendhttp "github.com/bytepowered/flux/backend/http"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Version JSON-RPC协议版本
+	Version = "2.0"
+)
+
+// BackendService的属性
+const (
+	// ServiceAttrTagParamsStyle 声明params的结构：[named, positional]；默认为named
+	ServiceAttrTagParamsStyle = "paramsstyle"
+)
+
+// params的结构
+const (
+	// ParamsStyleNamed 按参数名组成JSON对象
+	ParamsStyleNamed = "named"
+	// ParamsStylePositional 按参数声明顺序组成JSON数组
+	ParamsStylePositional = "positional"
+)
+
+// JSON-RPC 预定义的错误码
+// Ref: https://www.jsonrpc.org/specification#error_object
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+type (
+	// Request JSON-RPC请求对象
+	Request struct {
+		Version string      `json:"jsonrpc"`
+		ID      interface{} `json:"id"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}
+	// Response JSON-RPC响应对象
+	Response struct {
+		Version string      `json:"jsonrpc"`
+		ID      interface{} `json:"id"`
+		Result  interface{} `json:"result,omitempty"`
+		Error   *Error      `json:"error,omitempty"`
+	}
+	// Error JSON-RPC错误对象
+	Error struct {
+		Code    int         `json:"code"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data,omitempty"`
+	}
+)
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error, code: %d, message: %s", e.Code, e.Message)
+}
+
+// DefaultParamsAssembleFunc 默认实现：将服务的Arguments解析为命名参数对象或按位置的参数数组
+func DefaultParamsAssembleFunc(service flux.BackendService, ctx flux.Context) (interface{}, error) {
+	if len(service.Arguments) == 0 {
+		return nil, nil
+	}
+	style := strings.ToLower(service.GetAttr(ServiceAttrTagParamsStyle).GetString())
+	switch style {
+	case "", ParamsStyleNamed:
+		return backendhttp.AssembleJSONValues(service.Arguments, ctx)
+	case ParamsStylePositional:
+		params := make([]interface{}, len(service.Arguments))
+		for i, arg := range service.Arguments {
+			if len(arg.Fields) > 0 && nil == arg.ValueLoader {
+				fields, err := backendhttp.AssembleJSONValues(arg.Fields, ctx)
+				if nil != err {
+					return nil, err
+				}
+				params[i] = fields
+				continue
+			}
+			val, err := arg.Resolve(ctx)
+			if nil != err {
+				return nil, err
+			}
+			params[i] = val
+		}
+		return params, nil
+	default:
+		return nil, fmt.Errorf("unsupported jsonrpc params style: %s", style)
+	}
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, raw interface{}) (*flux.BackendResponse, error) {
+		resp, ok := raw.(*Response)
+		if !ok {
+			return nil, fmt.Errorf("unknown jsonrpc backend response: %T", raw)
+		}
+		return &flux.BackendResponse{
+			StatusCode: flux.StatusOK,
+			Headers:    make(http.Header, 0),
+			Body:       resp.Result,
+		}, nil
+	}
+}
+
+// NewErrorServeError 将JSON-RPC错误对象转换为ServeError；错误码和错误消息返回请求端，状态码按错误码映射
+func NewErrorServeError(err *Error) *flux.ServeError {
+	serr := &flux.ServeError{
+		StatusCode: HttpStatusFromCode(err.Code),
+		ErrorCode:  err.Code,
+		Message:    err.Message,
+		Internal:   err,
+	}
+	if nil != err.Data {
+		serr.PutExtraTrace("jsonrpc-data", err.Data)
+	}
+	return serr
+}
+
+// HttpStatusFromCode 映射JSON-RPC错误码到Http状态码；只有参数错误归因于请求端
+func HttpStatusFromCode(code int) int {
+	switch code {
+	case CodeInvalidParams:
+		return http.StatusBadRequest
+	case CodeMethodNotFound:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusBadGateway
+	}
+}
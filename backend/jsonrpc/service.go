@@ -0,0 +1,333 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	backendhttp "github.com/bytepowered/flux/backend/http"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	ConfigKeyBatchEnable = "batch_enable"
+)
+
+var (
+	ErrEmptyResponse      = errors.New("BACKEND:JR:EMPTY_RESPONSE")
+	ErrBatchResponseMiss  = errors.New("BACKEND:JR:BATCH_RESPONSE_MISSING")
+	ErrUnknownVersion     = errors.New("BACKEND:JR:UNKNOWN_VERSION")
+	ErrResponseIDMismatch = errors.New("BACKEND:JR:ID_MISMATCH")
+)
+
+var (
+	// excludeHeaders 不透传的原请求Header：逐跳Header，以及描述原请求/响应数据编码的Header。
+	// 透传Accept-Encoding时，HttpClient不会自动解压响应数据，压缩的响应数据无法解析为JSON。
+	excludeHeaders = []string{
+		flux.HeaderAcceptEncoding, flux.HeaderContentEncoding, flux.HeaderContentLength,
+		"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", flux.HeaderUpgrade,
+	}
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoJsonRpc, NewBackendTransportService())
+}
+
+var (
+	_ flux.BackendTransport        = new(BackendTransportService)
+	_ flux.BackendTransportBatcher = new(BackendTransportService)
+)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// ParamsAssembleFunc JSON-RPC请求params封装函数
+	ParamsAssembleFunc func(service flux.BackendService, ctx flux.Context) (interface{}, error)
+)
+
+// BackendTransportService JSON-RPC 2.0 over Http的Transport；
+// BackendService.Interface 为服务地址，Method 为RPC方法名；Http请求复用Http协议Transport的Header透传和超时控制。
+type BackendTransportService struct {
+	httpTransport      *backendhttp.BackendTransportService
+	responseCodecFunc  flux.BackendResponseCodecFunc
+	paramsAssembleFunc ParamsAssembleFunc
+	batchEnable        bool
+}
+
+// WithHttpClient 用于配置HttpClient客户端
+func WithHttpClient(client *http.Client) Option {
+	return func(service *BackendTransportService) {
+		service.httpTransport = backendhttp.NewBackendTransportServiceWith(backendhttp.WithHttpClient(client))
+	}
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+// WithParamsAssembleFunc 用于配置请求params封装实现函数
+func WithParamsAssembleFunc(fun ParamsAssembleFunc) Option {
+	return func(service *BackendTransportService) {
+		service.paramsAssembleFunc = fun
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith()
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		httpTransport:      backendhttp.NewBackendTransportServiceWith(backendhttp.WithHttpClient(&http.Client{})),
+		responseCodecFunc:  NewBackendResponseCodecFunc(),
+		paramsAssembleFunc: DefaultParamsAssembleFunc,
+		batchEnable:        true,
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("JsonRpc backend transport initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyBatchEnable: true,
+	})
+	b.batchEnable = config.GetBool(ConfigKeyBatchEnable)
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return b.decode(ctx, raw)
+}
+
+// Invoke 执行JSON-RPC调用，返回 *Response；响应为错误对象时，返回其映射的ServeError
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	request, serr := b.assemble(ctx, service, ctx.RequestId())
+	if nil != serr {
+		return nil, serr
+	}
+	data, serr := b.post(ctx, service, request)
+	if nil != serr {
+		return nil, serr
+	}
+	resp := new(Response)
+	if err := ext.JSONUnmarshal(data, resp); nil != err {
+		return nil, newDecodeServeError(err)
+	}
+	if nil != resp.Error {
+		return nil, NewErrorServeError(resp.Error)
+	}
+	if Version != resp.Version {
+		return nil, newDecodeServeError(ErrUnknownVersion)
+	}
+	if cast.ToString(resp.ID) != cast.ToString(request.ID) {
+		return nil, newDecodeServeError(ErrResponseIDMismatch)
+	}
+	return resp, nil
+}
+
+// BatchKey 相同服务地址的JSON-RPC调用可合并为批量请求
+func (b *BackendTransportService) BatchKey(service flux.BackendService) string {
+	if !b.batchEnable {
+		return ""
+	}
+	target, err := NewTargetURL(service)
+	if nil != err {
+		return ""
+	}
+	return target
+}
+
+// InvokeBatch 将多个JSON-RPC调用合并为一个批量请求；Http请求的Header和rpc-timeout按第一个服务的声明；
+// 各调用的响应数据按其服务声明的信封规则处理。
+func (b *BackendTransportService) InvokeBatch(ctx flux.Context, services []flux.BackendService) ([]*flux.BackendResponse, []*flux.ServeError) {
+	results, errs := make([]*flux.BackendResponse, len(services)), make([]*flux.ServeError, len(services))
+	requests := make([]*Request, 0, len(services))
+	for i, service := range services {
+		request, serr := b.assemble(ctx, service, i)
+		if nil != serr {
+			errs[i] = serr
+			continue
+		}
+		requests = append(requests, request)
+	}
+	if len(requests) == 0 {
+		return results, errs
+	}
+	failAll := func(serr *flux.ServeError) ([]*flux.BackendResponse, []*flux.ServeError) {
+		for _, req := range requests {
+			errs[req.ID.(int)] = serr
+		}
+		return results, errs
+	}
+	data, serr := b.post(ctx, services[requests[0].ID.(int)], requests)
+	if nil != serr {
+		return failAll(serr)
+	}
+	var responses []*Response
+	if err := ext.JSONUnmarshal(data, &responses); nil != err {
+		// 服务端无法处理批量请求时，返回单个错误对象
+		single := new(Response)
+		if nil == ext.JSONUnmarshal(data, single) && nil != single.Error {
+			return failAll(NewErrorServeError(single.Error))
+		}
+		return failAll(newDecodeServeError(err))
+	}
+	indexed := make(map[string]*Response, len(responses))
+	for _, resp := range responses {
+		if nil != resp {
+			indexed[cast.ToString(resp.ID)] = resp
+		}
+	}
+	for _, req := range requests {
+		i := req.ID.(int)
+		resp, ok := indexed[cast.ToString(i)]
+		switch {
+		case !ok:
+			errs[i] = newDecodeServeError(fmt.Errorf("%w, id: %d", ErrBatchResponseMiss, i))
+		case nil != resp.Error:
+			errs[i] = NewErrorServeError(resp.Error)
+		default:
+			results[i], errs[i] = b.decode(ctx, resp)
+		}
+		// 批量请求不经过 backend.DoInvokeCodec，在此按各服务的信封规则处理响应数据
+		if nil == errs[i] {
+			results[i], errs[i] = backend.DecodeResponseEnvelope(services[i], results[i])
+		}
+	}
+	return results, errs
+}
+
+func (b *BackendTransportService) decode(ctx flux.Context, raw interface{}) (*flux.BackendResponse, *flux.ServeError) {
+	result, err := b.responseCodecFunc(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	return result, nil
+}
+
+func (b *BackendTransportService) assemble(ctx flux.Context, service flux.BackendService, id interface{}) (*Request, *flux.ServeError) {
+	params, err := b.paramsAssembleFunc(service, ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageJsonRpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	if s, ok := id.(string); ok && "" == s {
+		id = 1
+	}
+	return &Request{Version: Version, ID: id, Method: service.Method, Params: params}, nil
+}
+
+// post 发送JSON-RPC请求，返回响应数据
+func (b *BackendTransportService) post(ctx flux.Context, service flux.BackendService, payload interface{}) ([]byte, *flux.ServeError) {
+	target, err := NewTargetURL(service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageJsonRpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	data, err := ext.JSONMarshal(payload)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageJsonRpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	request, err := http.NewRequestWithContext(ctx.Context(), http.MethodPost, target, bytes.NewReader(data))
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageJsonRpcAssembleFailed,
+			Internal:   err,
+		}
+	}
+	request.Header.Set(flux.HeaderContentType, flux.MIMEApplicationJSONCharsetUTF8)
+	request.Header.Set(flux.HeaderAccept, flux.MIMEApplicationJSON)
+	ret, serr := b.httpTransport.ExecuteRequestExcludes(request, service, ctx, excludeHeaders...)
+	if nil != serr {
+		return nil, serr
+	}
+	resp := ret.(*http.Response)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageJsonRpcInvokeFailed,
+			Internal:   err,
+		}
+	}
+	// 非2xx状态码的响应，可能仍然携带错误对象，由调用方解析
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageJsonRpcInvokeFailed,
+			Internal:   fmt.Errorf("%w, status: %d", ErrEmptyResponse, resp.StatusCode),
+		}
+	}
+	return body, nil
+}
+
+// NewTargetURL 返回服务地址；Interface为完整URL时直接使用，否则与Scheme和RemoteHost组合
+func NewTargetURL(service flux.BackendService) (string, error) {
+	if strings.Contains(service.Interface, "://") {
+		return service.Interface, nil
+	}
+	if "" == service.RemoteHost {
+		return "", fmt.Errorf("jsonrpc service url is invalid, interface: %s", service.Interface)
+	}
+	scheme := strings.ToLower(service.Scheme)
+	if "" == scheme {
+		scheme = "http"
+	}
+	return scheme + "://" + service.RemoteHost + service.Interface, nil
+}
+
+func newDecodeServeError(err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusBadGateway,
+		ErrorCode:  flux.ErrorCodeGatewayBackend,
+		Message:    flux.ErrorMessageJsonRpcDecodeFailed,
+		Internal:   err,
+	}
+}
@@ -0,0 +1,140 @@
+package jsonrpc
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/backend/aggregate"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// stdJsonSerializer 测试使用标准库JSON序列化
+type stdJsonSerializer struct{}
+
+func (s *stdJsonSerializer) Marshal(any interface{}) ([]byte, error) {
+	return json.Marshal(any)
+}
+
+func (s *stdJsonSerializer) Unmarshal(data []byte, obj interface{}) error {
+	return json.Unmarshal(data, obj)
+}
+
+func TestBackendTransportService_Invoke(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	ext.SetSerializer(ext.TypeNameSerializerJson, new(stdJsonSerializer))
+	var posts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		data, _ := ioutil.ReadAll(r.Body)
+		// 响应数据按客户端声明的编码压缩
+		writer := io.Writer(w)
+		if strings.Contains(r.Header.Get(flux.HeaderAcceptEncoding), "gzip") {
+			w.Header().Set(flux.HeaderContentEncoding, "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			writer = gz
+		}
+		handle := func(req Request) Response {
+			resp := Response{Version: Version, ID: req.ID}
+			switch req.Method {
+			case "user.get":
+				resp.Result = req.Params
+			case "order.list":
+				resp.Result = []string{"o1"}
+			case "account.get":
+				resp.Result = map[string]interface{}{"code": 0, "data": map[string]interface{}{"name": "n1"}}
+			default:
+				resp.Error = &Error{Code: CodeMethodNotFound, Message: "method not found"}
+			}
+			return resp
+		}
+		var out interface{}
+		if strings.HasPrefix(string(data), "[") {
+			var reqs []Request
+			_ = json.Unmarshal(data, &reqs)
+			resps := make([]Response, len(reqs))
+			for i, req := range reqs {
+				resps[i] = handle(req)
+			}
+			out = resps
+		} else {
+			var req Request
+			_ = json.Unmarshal(data, &req)
+			out = handle(req)
+		}
+		_ = json.NewEncoder(writer).Encode(out)
+	}))
+	defer server.Close()
+	newService := func(id, method string, args ...flux.Argument) flux.BackendService {
+		return flux.BackendService{
+			ServiceId: id,
+			Interface: server.URL + "/rpc",
+			Method:    method,
+			Arguments: args,
+			EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoJsonRpc},
+			}},
+		}
+	}
+	newContext := func() flux.Context {
+		return context.NewMockContext(map[string]interface{}{
+			"request-id": "req-1",
+			"uid":        "u1",
+			"body":       ioutil.NopCloser(strings.NewReader("")),
+			// 原请求的编码Header不透传
+			"header-values": http.Header{
+				flux.HeaderAcceptEncoding:  []string{"gzip, deflate"},
+				flux.HeaderContentEncoding: []string{"gzip"},
+			},
+		})
+	}
+	transport := NewBackendTransportService()
+	// 命名参数
+	resp, serr := transport.InvokeCodec(newContext(), newService("user", "user.get", ext.NewStringArgument("uid")))
+	tester.Nil(serr)
+	tester.Equal(map[string]interface{}{"uid": "u1"}, resp.Body)
+	// 错误对象
+	_, serr = transport.InvokeCodec(newContext(), newService("missing", "user.missing"))
+	tester.NotNil(serr)
+	tester.Equal(http.StatusNotImplemented, serr.StatusCode)
+	tester.Equal(CodeMethodNotFound, serr.ErrorCode)
+	tester.Equal("method not found", serr.Message)
+	// 聚合调用合并为批量请求
+	ext.SetBackendService(newService("test.jsonrpc.user", "user.get", ext.NewStringArgument("uid")))
+	ext.SetBackendService(newService("test.jsonrpc.order", "order.list"))
+	// 批量请求中的响应数据按服务的信封规则处理
+	envelope, err := backend.NewResponseEnvelopeOf("jsonrpc", flux.NewConfigurationOfMap(map[string]interface{}{}))
+	tester.NoError(err)
+	backend.SetResponseEnvelope(envelope)
+	account := newService("test.jsonrpc.account", "account.get")
+	account.Attributes = append(account.Attributes, flux.Attribute{Name: backend.ServiceAttrTagEnvelope, Value: "jsonrpc"})
+	ext.SetBackendService(account)
+	atomic.StoreInt32(&posts, 0)
+	ret, serr := aggregate.NewBackendTransportService().Invoke(newContext(), flux.BackendService{
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: aggregate.ServiceAttrTagAggregate, Value: "user:test.jsonrpc.user"},
+			{Name: aggregate.ServiceAttrTagAggregate, Value: "order:test.jsonrpc.order"},
+			{Name: aggregate.ServiceAttrTagAggregate, Value: "account:test.jsonrpc.account"},
+		}},
+	})
+	tester.Nil(serr)
+	tester.Equal(int32(1), atomic.LoadInt32(&posts))
+	tester.Equal(map[string]interface{}{
+		"user":    map[string]interface{}{"uid": "u1"},
+		"order":   []interface{}{"o1"},
+		"account": map[string]interface{}{"name": "n1"},
+	}, ret)
+}
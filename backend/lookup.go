@@ -38,11 +38,18 @@ func DefaultArgumentLookupFunc(scope, key string, ctx flux.Context) (value flux.
 		return flux.WrapStringMTValue(req.HeaderVar(key)), nil
 	case flux.ScopeHeaderMap:
 		return flux.WrapStrValuesMapMTValue(req.HeaderVars()), nil
+	case flux.ScopeCookie:
+		if cookie := req.CookieVar(key); nil != cookie {
+			return flux.WrapStringMTValue(cookie.Value), nil
+		}
+		return flux.WrapStringMTValue(""), nil
 	case flux.ScopeAttr:
 		v, _ := ctx.GetAttribute(key)
 		return flux.WrapObjectMTValue(v), nil
 	case flux.ScopeAttrs:
 		return flux.WrapStrMapMTValue(ctx.Attributes()), nil
+	case flux.ScopeValue:
+		return flux.WrapObjectMTValue(LookupValuePath(key, ctx)), nil
 	case flux.ScopeBody:
 		reader, err := req.BodyReader()
 		return flux.MTValue{Value: reader, MediaType: req.HeaderVar(flux.HeaderContentType)}, err
@@ -55,6 +62,8 @@ func DefaultArgumentLookupFunc(scope, key string, ctx flux.Context) (value flux.
 			return flux.WrapStringMTValue(ctx.Method()), nil
 		case "uri":
 			return flux.WrapStringMTValue(ctx.URI()), nil
+		case "address":
+			return flux.WrapStringMTValue(req.Address()), nil
 		default:
 			return flux.WrapStringMTValue(""), nil
 		}
@@ -72,3 +81,30 @@ func DefaultArgumentLookupFunc(scope, key string, ctx flux.Context) (value flux.
 		return flux.WrapObjectMTValue(nil), nil
 	}
 }
+
+// LookupValuePath 查找Context.Variable的值；Key支持以"."分隔的嵌套路径，逐级查找Map结构的字段值。
+func LookupValuePath(key string, ctx flux.Context) interface{} {
+	if v, ok := ctx.GetVariable(key); ok {
+		return v
+	}
+	paths := strings.Split(key, ".")
+	// 优先匹配最长的Variable名称
+	for i := len(paths) - 1; i > 0; i-- {
+		v, ok := ctx.GetVariable(strings.Join(paths[:i], "."))
+		if !ok {
+			continue
+		}
+		for _, field := range paths[i:] {
+			switch m := v.(type) {
+			case map[string]interface{}:
+				v = m[field]
+			case map[interface{}]interface{}:
+				v = m[field]
+			default:
+				return nil
+			}
+		}
+		return v
+	}
+	return nil
+}
@@ -1,7 +1,8 @@
-package backend
+package backend_test
 
 import (
 	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
 	"github.com/bytepowered/flux/context"
 	assert2 "github.com/stretchr/testify/assert"
 	"net/http"
@@ -12,10 +13,10 @@ import (
 func TestNilContext(t *testing.T) {
 	assert := assert2.New(t)
 	// Scope & key
-	_, err0 := DefaultArgumentLookupFunc("", "", context.NewEmptyContext())
+	_, err0 := backend.DefaultArgumentLookupFunc("", "", context.NewEmptyContext())
 	assert.Error(err0, "must error")
 	// Nil context
-	_, err1 := DefaultArgumentLookupFunc("a", "b", nil)
+	_, err1 := backend.DefaultArgumentLookupFunc("a", "b", nil)
 	assert.Error(err1, "must error")
 }
 
@@ -55,7 +56,7 @@ func TestDefaultArgumentValueLookupFunc(t *testing.T) {
 	}
 	assert := assert2.New(t)
 	for _, c := range cases {
-		mtv, err := DefaultArgumentLookupFunc(c.scope, c.key, valctx)
+		mtv, err := backend.DefaultArgumentLookupFunc(c.scope, c.key, valctx)
 		assert.NoError(err, "must no error")
 		assert.Equal(c.expect, mtv)
 	}
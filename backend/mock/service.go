@@ -0,0 +1,341 @@
+package mock
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+	"github.com/spf13/cast"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BackendService.Extensions 或 Endpoint.Extensions：模拟响应的配置
+const (
+	ExtensionKeyStatus   = "mock-status"
+	ExtensionKeyHeaders  = "mock-headers"
+	ExtensionKeyBody     = "mock-body"
+	ExtensionKeyLatency  = "mock-latency"
+	ExtensionKeyExamples = "mock-examples"
+)
+
+// Example对象的字段
+const (
+	exampleKeyMatch   = "match"
+	exampleKeyStatus  = "status"
+	exampleKeyHeaders = "headers"
+	exampleKeyBody    = "body"
+	exampleKeyLatency = "latency"
+)
+
+var (
+	// 模板变量：${scope:key}
+	templateVarPattern = regexp.MustCompile(`\$\{([^}]+)}`)
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoMock, NewBackendTransportService())
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// Example 模拟响应定义
+	Example struct {
+		Match   map[string]string
+		Status  int
+		Headers http.Header
+		Body    interface{}
+		Latency time.Duration
+	}
+)
+
+// BackendTransportService 根据BackendService或Endpoint的Extensions配置返回模拟响应的Transport；
+// 响应Body模板中可以通过 ${scope:key} 引用请求参数值，Content-Type为JSON时参数值按JSON字符串转义；
+// 通过 mock-examples 配置多个响应样例，按请求参数匹配选择，都不匹配时使用默认的响应配置。
+type BackendTransportService struct {
+	responseCodecFunc flux.BackendResponseCodecFunc
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+func NewBackendTransportService() flux.BackendTransport {
+	return NewBackendTransportServiceWith(WithResponseCodecFunc(NewBackendResponseCodecFunc()))
+}
+
+func NewBackendTransportServiceWith(opts ...Option) flux.BackendTransport {
+	bts := &BackendTransportService{}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.responseCodecFunc(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	return result, nil
+}
+
+// Invoke 选择匹配请求的响应样例，渲染模板后返回 *flux.BackendResponse
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	example, err := SelectExample(ctx, LookupExtensions(ctx, service))
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageMockIllegalExtensions,
+			Internal:   err,
+		}
+	}
+	if example.Latency > 0 {
+		timer := time.NewTimer(example.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Context().Done():
+			timer.Stop()
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusBadGateway,
+				ErrorCode:  flux.ErrorCodeGatewayBackend,
+				Message:    flux.ErrorMessageMockInvokeCanceled,
+				Internal:   ctx.Context().Err(),
+			}
+		}
+	}
+	header := make(http.Header, len(example.Headers))
+	for k, vs := range example.Headers {
+		for _, v := range vs {
+			header.Add(k, RenderTemplate(ctx, v))
+		}
+	}
+	var body interface{}
+	if text, ok := example.Body.(string); ok {
+		// 文本模板：按原始文本输出；JSON文本中的参数值按JSON字符串转义，避免参数值破坏JSON结构
+		if strings.Contains(strings.ToLower(header.Get(flux.HeaderContentType)), "json") {
+			body = strings.NewReader(RenderTemplateWith(ctx, text, func(value string) string {
+				return escapeJSONString(ctx, value)
+			}))
+		} else {
+			body = strings.NewReader(RenderTemplate(ctx, text))
+		}
+	} else {
+		body = renderValue(ctx, example.Body)
+	}
+	return &flux.BackendResponse{
+		StatusCode: example.Status,
+		Headers:    header,
+		Body:       body,
+	}, nil
+}
+
+// LookupExtensions 返回模拟响应的配置：优先使用BackendService.Extensions，未声明时使用Endpoint.Extensions；
+// 作为聚合或链式调用的子服务时，使用子服务自身的配置。
+func LookupExtensions(ctx flux.Context, service flux.BackendService) flux.EmbeddedExtensions {
+	for _, key := range []string{ExtensionKeyStatus, ExtensionKeyHeaders, ExtensionKeyBody, ExtensionKeyLatency, ExtensionKeyExamples} {
+		if _, ok := service.GetValue(key); ok {
+			return service.EmbeddedExtensions
+		}
+	}
+	return ctx.Endpoint().EmbeddedExtensions
+}
+
+// SelectExample 按请求参数选择匹配的响应样例；都不匹配时，返回默认的响应配置
+func SelectExample(ctx flux.Context, extensions flux.EmbeddedExtensions) (Example, error) {
+	defaults, err := parseExample(map[string]interface{}{
+		exampleKeyStatus:  extensions.Extensions[ExtensionKeyStatus],
+		exampleKeyHeaders: extensions.Extensions[ExtensionKeyHeaders],
+		exampleKeyBody:    extensions.Extensions[ExtensionKeyBody],
+		exampleKeyLatency: extensions.Extensions[ExtensionKeyLatency],
+	}, Example{Status: flux.StatusOK})
+	if nil != err {
+		return defaults, err
+	}
+	if v, ok := extensions.GetValue(ExtensionKeyExamples); ok && nil != v {
+		items, err := cast.ToSliceE(v)
+		if nil != err {
+			return defaults, fmt.Errorf("illegal %s: %w", ExtensionKeyExamples, err)
+		}
+		for _, item := range items {
+			m, err := cast.ToStringMapE(item)
+			if nil != err {
+				return defaults, fmt.Errorf("illegal %s item: %w", ExtensionKeyExamples, err)
+			}
+			example, err := parseExample(m, defaults)
+			if nil != err {
+				return defaults, err
+			}
+			if matchExample(ctx, example.Match) {
+				return example, nil
+			}
+		}
+	}
+	return defaults, nil
+}
+
+// RenderTemplate 替换模板中的 ${scope:key} 变量为请求参数值
+func RenderTemplate(ctx flux.Context, template string) string {
+	return RenderTemplateWith(ctx, template, func(value string) string {
+		return value
+	})
+}
+
+// RenderTemplateWith 替换模板中的 ${scope:key} 变量为请求参数值，参数值经escape函数转义后写入
+func RenderTemplateWith(ctx flux.Context, template string, escape func(string) string) string {
+	if !strings.Contains(template, "${") {
+		return template
+	}
+	return templateVarPattern.ReplaceAllStringFunc(template, func(expr string) string {
+		return escape(lookupValue(ctx, expr[2:len(expr)-1]))
+	})
+}
+
+// escapeJSONString 返回JSON字符串转义后的文本，不包含首尾引号
+func escapeJSONString(ctx flux.Context, value string) string {
+	data, err := ext.JSONMarshal(value)
+	if nil != err || len(data) < 2 {
+		logger.WithContext(ctx).Warnw("BACKEND:MOCK:ESCAPE", "value", value, "error", err)
+		return ""
+	}
+	return string(data[1 : len(data)-1])
+}
+
+func renderValue(ctx flux.Context, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return RenderTemplate(ctx, v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, iv := range v {
+			out[k] = renderValue(ctx, iv)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, iv := range v {
+			out[cast.ToString(k)] = renderValue(ctx, iv)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, iv := range v {
+			out[i] = renderValue(ctx, iv)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+func parseExample(m map[string]interface{}, defaults Example) (Example, error) {
+	example := Example{
+		Status:  defaults.Status,
+		Headers: defaults.Headers,
+		Body:    defaults.Body,
+		Latency: defaults.Latency,
+	}
+	if v, ok := m[exampleKeyMatch]; ok && nil != v {
+		match, err := cast.ToStringMapStringE(v)
+		if nil != err {
+			return example, fmt.Errorf("illegal mock match: %w", err)
+		}
+		example.Match = match
+	}
+	if v, ok := m[exampleKeyStatus]; ok && nil != v {
+		status, err := cast.ToIntE(v)
+		if nil != err {
+			return example, fmt.Errorf("illegal mock status: %w", err)
+		}
+		example.Status = status
+	}
+	if v, ok := m[exampleKeyHeaders]; ok && nil != v {
+		headers, err := cast.ToStringMapStringE(v)
+		if nil != err {
+			return example, fmt.Errorf("illegal mock headers: %w", err)
+		}
+		example.Headers = make(http.Header, len(headers))
+		for k, hv := range headers {
+			example.Headers.Set(k, hv)
+		}
+	}
+	if v, ok := m[exampleKeyBody]; ok && nil != v {
+		example.Body = v
+	}
+	if v, ok := m[exampleKeyLatency]; ok && nil != v {
+		latency, err := cast.ToDurationE(v)
+		if nil != err {
+			return example, fmt.Errorf("illegal mock latency: %w", err)
+		}
+		example.Latency = latency
+	}
+	return example, nil
+}
+
+// matchExample 样例的全部匹配条件都与请求参数值相等时，返回True
+func matchExample(ctx flux.Context, match map[string]string) bool {
+	if len(match) == 0 {
+		return false
+	}
+	for expr, expected := range match {
+		if lookupValue(ctx, expr) != expected {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupValue(ctx flux.Context, expr string) string {
+	scope, key, ok := pkg.LookupParseExpr(expr)
+	if !ok {
+		scope, key = flux.ScopeAuto, expr
+	}
+	mtv, err := ext.GetArgumentLookupFunc()(scope, key, ctx)
+	if nil != err {
+		logger.WithContext(ctx).Warnw("BACKEND:MOCK:LOOKUP", "expr", expr, "error", err)
+		return ""
+	}
+	value, err := backend.CastDecodeMTValueToString(mtv)
+	if nil != err {
+		return ""
+	}
+	return value
+}
+
+// NewBackendResponseCodecFunc 模拟响应已经是 *flux.BackendResponse，直接返回
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		if resp, ok := value.(*flux.BackendResponse); ok {
+			return resp, nil
+		}
+		return nil, fmt.Errorf("unknown mock response: %T", value)
+	}
+}
@@ -0,0 +1,93 @@
+package mock
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestSelectExample(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	extensions := flux.EmbeddedExtensions{
+		Extensions: map[string]interface{}{
+			ExtensionKeyStatus:  201,
+			ExtensionKeyHeaders: map[string]interface{}{"X-Mock": "default"},
+			ExtensionKeyBody:    `{"id": "${query:id}"}`,
+			ExtensionKeyExamples: []interface{}{
+				map[string]interface{}{
+					"match":   map[string]interface{}{"query:id": "404"},
+					"status":  404,
+					"body":    map[string]interface{}{"error": "user ${query:id} not found"},
+					"latency": "10ms",
+				},
+			},
+		},
+	}
+	cases := []struct {
+		id      string
+		status  int
+		latency time.Duration
+	}{
+		{id: "1", status: 201},
+		{id: "404", status: 404, latency: 10 * time.Millisecond},
+	}
+	for _, c := range cases {
+		ctx := context.NewMockContext(map[string]interface{}{"id": c.id})
+		example, err := SelectExample(ctx, extensions)
+		tester.NoError(err)
+		tester.Equal(c.status, example.Status)
+		tester.Equal(c.latency, example.Latency)
+		tester.Equal("default", example.Headers.Get("X-Mock"))
+	}
+	ctx := context.NewMockContext(map[string]interface{}{"id": "404"})
+	example, _ := SelectExample(ctx, extensions)
+	tester.Equal(map[string]interface{}{"error": "user 404 not found"}, renderValue(ctx, example.Body))
+}
+
+func TestBackendTransportService_Invoke(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	ctx := context.NewMockContext(map[string]interface{}{"id": "1"})
+	resp, serr := NewBackendTransportService().InvokeCodec(ctx, flux.BackendService{})
+	tester.Nil(serr)
+	tester.Equal(flux.StatusOK, resp.StatusCode)
+	tester.Nil(resp.Body)
+	tester.Equal(`{"id": "1"}`, RenderTemplate(ctx, `{"id": "${query:id}"}`))
+	// 子服务声明的模拟响应配置优先于Endpoint的配置
+	ctx = context.NewMockContext(map[string]interface{}{
+		"endpoint": flux.Endpoint{EmbeddedExtensions: flux.EmbeddedExtensions{
+			Extensions: map[string]interface{}{ExtensionKeyStatus: 200, ExtensionKeyBody: "endpoint"},
+		}},
+	})
+	part := flux.BackendService{EmbeddedExtensions: flux.EmbeddedExtensions{
+		Extensions: map[string]interface{}{ExtensionKeyStatus: 201, ExtensionKeyBody: "part"},
+	}}
+	resp, serr = NewBackendTransportService().InvokeCodec(ctx, part)
+	tester.Nil(serr)
+	tester.Equal(201, resp.StatusCode)
+	resp, serr = NewBackendTransportService().InvokeCodec(ctx, flux.BackendService{})
+	tester.Nil(serr)
+	tester.Equal(200, resp.StatusCode)
+	// JSON文本模板：参数值按JSON字符串转义
+	ext.SetSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	ctx = context.NewMockContext(map[string]interface{}{"name": `a"b\c`})
+	resp, serr = NewBackendTransportService().InvokeCodec(ctx, flux.BackendService{EmbeddedExtensions: flux.EmbeddedExtensions{
+		Extensions: map[string]interface{}{
+			ExtensionKeyHeaders: map[string]interface{}{flux.HeaderContentType: flux.MIMEApplicationJSONCharsetUTF8},
+			ExtensionKeyBody:    `{"name": "${query:name}"}`,
+		},
+	}})
+	tester.Nil(serr)
+	data, err := ioutil.ReadAll(resp.Body.(io.Reader))
+	tester.NoError(err)
+	var body map[string]interface{}
+	tester.NoError(flux.NewJsonSerializer().Unmarshal(data, &body))
+	tester.Equal(map[string]interface{}{"name": `a"b\c`}, body)
+}
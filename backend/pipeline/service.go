@@ -0,0 +1,212 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"net/http"
+	"strings"
+)
+
+const (
+	ConfigKeyTraceEnable = "trace_enable"
+)
+
+const (
+	// VariableKeyPrefix 步骤响应数据保存到Context.Variable的键前缀，避免与Filter等设置的Variable冲突
+	VariableKeyPrefix = "pipeline."
+)
+
+const (
+	// ServiceAttrTagPipeline 声明一个调用步骤，按声明顺序执行，可声明多个。
+	// 属性值格式为字符串 "{name}:{serviceId}"，或者对象 {name, service}
+	ServiceAttrTagPipeline = "pipeline"
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoPipeline, NewBackendTransportService())
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+	// Step 调用步骤定义
+	Step struct {
+		Name      string
+		ServiceId string
+	}
+)
+
+// BackendTransportService 按顺序链式调用多个BackendService的Transport；
+// 每个步骤的响应数据以 pipeline.{name} 为键保存到Context.Variable中，后续步骤的参数可以通过 value:pipeline.{name}.{field} 引用；
+// 最后一个步骤的响应结果作为整个调用的响应结果。
+// 注意：步骤响应数据不保存到Context.Attribute，避免作为Attachment透传到后续的后端服务。
+type BackendTransportService struct {
+	responseCodecFunc flux.BackendResponseCodecFunc
+	traceEnable       bool
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+func NewBackendTransportService() flux.BackendTransport {
+	return NewBackendTransportServiceWith(WithResponseCodecFunc(NewBackendResponseCodecFunc()))
+}
+
+func NewBackendTransportServiceWith(opts ...Option) flux.BackendTransport {
+	bts := &BackendTransportService{}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("Pipeline backend transport initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyTraceEnable: false,
+	})
+	b.traceEnable = config.GetBool(ConfigKeyTraceEnable)
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.responseCodecFunc(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	return result, nil
+}
+
+// Invoke 按顺序执行各步骤，返回最后一个步骤的响应结果
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	steps, err := ParseSteps(service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessagePipelineAssembleFailed,
+			Internal:   err,
+		}
+	}
+	var resp *flux.BackendResponse
+	for i, step := range steps {
+		stepService, ok := ext.GetBackendService(step.ServiceId)
+		if !ok {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessagePipelineServiceNotFound,
+				Internal:   fmt.Errorf("pipeline service not found, step: %s, service-id: %s", step.Name, step.ServiceId),
+			}
+		}
+		ret, serr := backend.DoInvokeCodec(ctx, stepService)
+		if nil != serr {
+			return nil, serr
+		}
+		if b.traceEnable {
+			logger.WithContext(ctx).Infow("BACKEND:PIPELINE:STEP",
+				"step", step.Name, "service-id", step.ServiceId, "status", ret.StatusCode)
+		}
+		// 最后一个步骤：直接返回响应结果
+		if i == len(steps)-1 {
+			resp = ret
+			break
+		}
+		if ret.StatusCode < http.StatusOK || ret.StatusCode >= http.StatusBadRequest {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusBadGateway,
+				ErrorCode:  flux.ErrorCodeGatewayBackend,
+				Message:    flux.ErrorMessagePipelineStepFailed,
+				Internal:   fmt.Errorf("pipeline step failed, step: %s, status: %d", step.Name, ret.StatusCode),
+			}
+		}
+		body, err := backend.DecodeResponseBody(ret.Body)
+		if nil != err {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessageBackendDecodeResponse,
+				Internal:   err,
+			}
+		}
+		ctx.SetVariable(StepVariableKey(step.Name), body)
+	}
+	return resp, nil
+}
+
+// StepVariableKey 返回步骤响应数据保存到Context.Variable的键
+func StepVariableKey(name string) string {
+	return VariableKeyPrefix + name
+}
+
+// ParseSteps 解析服务属性中声明的调用步骤列表
+func ParseSteps(service flux.BackendService) ([]Step, error) {
+	attrs := service.GetAttrs(ServiceAttrTagPipeline)
+	if len(attrs) == 0 {
+		return nil, fmt.Errorf("pipeline steps not found, service: %s", service.ServiceID())
+	}
+	steps := make([]Step, 0, len(attrs))
+	names := make(map[string]struct{}, len(attrs))
+	for _, attr := range attrs {
+		var step Step
+		if text, ok := attr.Value.(string); ok {
+			kv := strings.SplitN(text, ":", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("illegal pipeline step: %s", text)
+			}
+			step.Name, step.ServiceId = strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		} else {
+			m, err := cast.ToStringMapE(attr.Value)
+			if nil != err {
+				return nil, fmt.Errorf("illegal pipeline step: %v, err: %w", attr.Value, err)
+			}
+			step.Name, step.ServiceId = cast.ToString(m["name"]), cast.ToString(m["service"])
+		}
+		if "" == step.Name || "" == step.ServiceId {
+			return nil, fmt.Errorf("pipeline step name and service are required, step: %v", attr.Value)
+		}
+		if _, dup := names[step.Name]; dup {
+			return nil, fmt.Errorf("duplicated pipeline step name: %s", step.Name)
+		}
+		names[step.Name] = struct{}{}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// NewBackendResponseCodecFunc 最后一个步骤的响应结果已经解析，直接返回
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, value interface{}) (*flux.BackendResponse, error) {
+		if resp, ok := value.(*flux.BackendResponse); ok {
+			return resp, nil
+		}
+		return nil, fmt.Errorf("unknown pipeline response: %T", value)
+	}
+}
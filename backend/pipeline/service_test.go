@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	_ "github.com/bytepowered/flux/backend/echo"
+	"github.com/bytepowered/flux/backend/mock"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestBackendTransportService_Invoke(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetBackendService(flux.BackendService{
+		ServiceId: "test.pipeline.echo",
+		Interface: "test.pipeline",
+		Method:    "echo",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoEcho}},
+		},
+	})
+	service := flux.BackendService{
+		Interface: "test.pipeline",
+		Method:    "chain",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoPipeline},
+				{Name: ServiceAttrTagPipeline, Value: "user:test.pipeline.echo"},
+				{Name: ServiceAttrTagPipeline, Value: map[string]interface{}{"name": "order", "service": "test.pipeline.echo"}},
+			},
+		},
+	}
+	ctx := context.NewMockContext(map[string]interface{}{
+		"body":       ioutil.NopCloser(strings.NewReader("")),
+		"request-id": "pipeline-001",
+	})
+	// Filter设置的同名Variable，不被步骤响应数据覆盖
+	ctx.SetVariable("user", "filter-user")
+	resp, serr := NewBackendTransportService().InvokeCodec(ctx, service)
+	tester.Nil(serr)
+	tester.Equal(flux.StatusOK, resp.StatusCode)
+	// 中间步骤的响应数据保存到Variable，最后步骤不保存
+	_, ok := ctx.GetVariable(StepVariableKey("user"))
+	tester.True(ok)
+	_, ok = ctx.GetVariable(StepVariableKey("order"))
+	tester.False(ok)
+	user, _ := ctx.GetVariable("user")
+	tester.Equal("filter-user", user)
+	mtv, err := backend.DefaultArgumentLookupFunc(flux.ScopeValue, "pipeline.user.request-id", ctx)
+	tester.NoError(err)
+	tester.Equal("pipeline-001", mtv.Value)
+	// 重复的步骤名称
+	service.Attributes = append(service.Attributes, flux.Attribute{Name: ServiceAttrTagPipeline, Value: "user:test.pipeline.echo"})
+	_, err = ParseSteps(service)
+	tester.Error(err)
+}
+
+func TestBackendTransportService_InvokeWithEnvelope(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	envelope, err := backend.NewResponseEnvelopeOf("pipeline", flux.NewConfigurationOfMap(map[string]interface{}{}))
+	tester.NoError(err)
+	backend.SetResponseEnvelope(envelope)
+	backend.SetDefaultResponseEnvelope("pipeline")
+	defer backend.SetDefaultResponseEnvelope("")
+	ext.SetBackendService(flux.BackendService{
+		ServiceId: "test.pipeline.mock",
+		Interface: "test.pipeline",
+		Method:    "mock",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoMock}},
+		},
+		EmbeddedExtensions: flux.EmbeddedExtensions{Extensions: map[string]interface{}{
+			mock.ExtensionKeyHeaders: map[string]interface{}{flux.HeaderContentType: flux.MIMEApplicationJSON},
+			mock.ExtensionKeyBody:    `{"code":0,"data":{"code":"x"}}`,
+		}},
+	})
+	service := flux.BackendService{
+		Interface: "test.pipeline",
+		Method:    "chain",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoPipeline},
+				{Name: ServiceAttrTagPipeline, Value: "first:test.pipeline.mock"},
+				{Name: ServiceAttrTagPipeline, Value: "last:test.pipeline.mock"},
+			},
+		},
+	}
+	ctx := context.NewMockContext(map[string]interface{}{
+		"body":       ioutil.NopCloser(strings.NewReader("")),
+		"request-id": "pipeline-002",
+	})
+	// 子服务已按信封规则解出data，链式调用服务不再重复处理
+	resp, serr := backend.DoInvokeCodec(ctx, service)
+	tester.Nil(serr)
+	tester.Equal(flux.StatusOK, resp.StatusCode)
+	data, err := ioutil.ReadAll(resp.Body.(io.Reader))
+	tester.NoError(err)
+	tester.Equal(`{"code":"x"}`, string(data))
+}
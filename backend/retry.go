@@ -0,0 +1,213 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cast"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Service重试相关属性
+const (
+	ServiceAttrTagRetryOn         = "retryon"         // 重试条件，逗号分隔，例如：connect-error,backend-error,5xx,503；未声明时，非Http协议默认包含backend-error
+	ServiceAttrTagRetryBackoff    = "retrybackoff"    // 重试的初始退避时间，例如：50ms
+	ServiceAttrTagRetryMaxBackoff = "retrymaxbackoff" // 重试的最大退避时间，例如：1s
+	ServiceAttrTagIdempotent      = "idempotent"      // 标识服务是否幂等；未声明时，Http协议按请求方法判断，其它协议视为非幂等，不重试
+)
+
+// 重试条件
+const (
+	RetryOnConnectError = "connect-error" // 网络连接错误：建立连接失败、连接被拒绝或重置
+	RetryOnBackendError = "backend-error" // 后端服务调用错误，即错误码为 GATEWAY:BACKEND 的错误
+	RetryOn5xx          = "5xx"           // 后端服务响应5xx状态码
+)
+
+const (
+	defaultRetryOn         = RetryOnConnectError + ",502,503,504"
+	defaultRpcRetryOn      = defaultRetryOn + "," + RetryOnBackendError // 非Http协议的调用失败以 GATEWAY:BACKEND 错误返回
+	defaultRetryBackoff    = 50 * time.Millisecond
+	defaultRetryMaxBackoff = time.Second
+)
+
+var (
+	retryCounter *prometheus.CounterVec
+)
+
+// SetRetryCounter 设置记录重试次数的Metric，标签为：ProtoName, Interface, Method, Reason；未设置时不记录
+func SetRetryCounter(counter *prometheus.CounterVec) {
+	retryCounter = counter
+}
+
+// RetryPolicy 后端服务调用的重试策略，由BackendService的属性声明
+type RetryPolicy struct {
+	Retries    int           // 最大重试次数，不包含首次调用
+	Backoff    time.Duration // 初始退避时间，按指数增长，并叠加随机抖动
+	MaxBackoff time.Duration // 最大退避时间
+	RetryOn    []string      // 重试条件
+	Idempotent bool          // 是否幂等；非幂等服务不重试
+}
+
+// NewRetryPolicy 根据BackendService的属性创建重试策略
+func NewRetryPolicy(service flux.BackendService) RetryPolicy {
+	policy := RetryPolicy{
+		Retries:    cast.ToInt(service.AttrRpcRetries()),
+		Backoff:    defaultRetryBackoff,
+		MaxBackoff: defaultRetryMaxBackoff,
+		Idempotent: isIdempotentService(service),
+	}
+	// 聚合和链式调用服务由子服务各自重试，避免重复执行已成功的子服务
+	if IsCompositeService(service) {
+		policy.Retries = 0
+	}
+	if d, err := time.ParseDuration(service.GetAttr(ServiceAttrTagRetryBackoff).GetString()); nil == err && d > 0 {
+		policy.Backoff = d
+	}
+	if d, err := time.ParseDuration(service.GetAttr(ServiceAttrTagRetryMaxBackoff).GetString()); nil == err && d > 0 {
+		policy.MaxBackoff = d
+	}
+	retryOn := service.GetAttr(ServiceAttrTagRetryOn).GetString()
+	if "" == retryOn {
+		if strings.EqualFold(flux.ProtoHttp, service.AttrRpcProto()) {
+			retryOn = defaultRetryOn
+		} else {
+			retryOn = defaultRpcRetryOn
+		}
+	}
+	for _, on := range strings.Split(retryOn, ",") {
+		if on = strings.ToLower(strings.TrimSpace(on)); "" != on {
+			policy.RetryOn = append(policy.RetryOn, on)
+		}
+	}
+	return policy
+}
+
+// Enabled 返回是否允许重试
+func (p RetryPolicy) Enabled() bool {
+	return p.Retries > 0 && p.Idempotent
+}
+
+// RetryReason 判断调用结果是否满足重试条件，返回满足的重试条件；不满足时返回空字符串
+func (p RetryPolicy) RetryReason(resp *flux.BackendResponse, serr *flux.ServeError) string {
+	for _, on := range p.RetryOn {
+		switch on {
+		case RetryOnConnectError:
+			if nil != serr && isConnectError(serr.Internal) {
+				return on
+			}
+		case RetryOnBackendError:
+			if nil != serr && flux.ErrorCodeGatewayBackend == serr.ErrorCode {
+				return on
+			}
+		case RetryOn5xx:
+			if nil != resp && resp.StatusCode >= http.StatusInternalServerError {
+				return on
+			}
+		default:
+			if code, err := strconv.Atoi(on); nil == err && nil != resp && resp.StatusCode == code {
+				return on
+			}
+		}
+	}
+	return ""
+}
+
+// BackoffOf 返回第N次重试前的退避时间：指数增长，并在 [d/2, d] 范围内随机抖动
+func (p RetryPolicy) BackoffOf(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	return d
+}
+
+// DoInvokeCodecWithRetry 按BackendService声明的重试策略执行后端服务；
+// 重试不会超出请求的截止时间，剩余时间不足以等待退避时，返回最后一次调用结果。
+func DoInvokeCodecWithRetry(ctx flux.Context, transport flux.BackendTransport, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	policy := NewRetryPolicy(service)
+	resp, serr := transport.InvokeCodec(ctx, service)
+	if !policy.Enabled() {
+		return resp, serr
+	}
+	for retry := 1; retry <= policy.Retries; retry++ {
+		reason := policy.RetryReason(resp, serr)
+		if "" == reason || !waitBackoff(ctx.Context(), policy.BackoffOf(retry)) {
+			break
+		}
+		// 放弃上一次调用的响应数据
+		if nil != resp {
+			if closer, ok := resp.Body.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+		logger.WithContext(ctx).Infow("BACKEND:RETRY",
+			"backend-service", service.ServiceID(), "retry", retry, "reason", reason)
+		if nil != retryCounter {
+			retryCounter.WithLabelValues(service.AttrRpcProto(), service.Interface, service.Method, reason).Inc()
+		}
+		ctx.AddMetric(fmt.Sprintf("M-Retry-%d", retry), time.Since(ctx.StartAt()))
+		resp, serr = transport.InvokeCodec(ctx, service)
+	}
+	return resp, serr
+}
+
+// waitBackoff 等待退避时间；请求剩余时间不足或已取消时，返回false
+func waitBackoff(ctx context.Context, backoff time.Duration) bool {
+	if nil != ctx.Err() {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+		return false
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func isIdempotentService(service flux.BackendService) bool {
+	if attr := service.GetAttr(ServiceAttrTagIdempotent); "" != attr.Name {
+		return attr.GetBool()
+	}
+	// 非Http协议无法判断调用是否幂等，例如Dubbo的业务异常可能发生在写操作之后
+	if !strings.EqualFold(flux.ProtoHttp, service.AttrRpcProto()) {
+		return false
+	}
+	switch strings.ToUpper(service.Method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func isConnectError(err error) bool {
+	if nil == err {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && "dial" == opErr.Op
+}
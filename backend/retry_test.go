@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRetryPolicy_RetryReason(t *testing.T) {
+	tester := assert.New(t)
+	policy := NewRetryPolicy(flux.BackendService{
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoHttp},
+		}},
+	})
+	tester.Equal(RetryOnConnectError, policy.RetryReason(nil, &flux.ServeError{
+		Internal: &url.Error{Op: "Get", Err: &net.OpError{Op: "dial"}},
+	}))
+	tester.Equal("502", policy.RetryReason(&flux.BackendResponse{StatusCode: http.StatusBadGateway}, nil))
+	tester.Equal("", policy.RetryReason(&flux.BackendResponse{StatusCode: http.StatusInternalServerError}, nil))
+	tester.Equal("", policy.RetryReason(nil, &flux.ServeError{ErrorCode: flux.ErrorCodeGatewayBackend}))
+	for retry := 1; retry < 10; retry++ {
+		tester.True(policy.BackoffOf(retry) <= policy.MaxBackoff)
+	}
+	// 非Http协议：默认重试后端服务调用错误
+	policy = NewRetryPolicy(flux.BackendService{
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoDubbo},
+		}},
+	})
+	tester.Equal(RetryOnBackendError, policy.RetryReason(nil, &flux.ServeError{ErrorCode: flux.ErrorCodeGatewayBackend}))
+}
+
+func TestRetryPolicy_Enabled(t *testing.T) {
+	tester := assert.New(t)
+	newPolicy := func(proto string, attrs ...flux.Attribute) RetryPolicy {
+		attrs = append(attrs,
+			flux.Attribute{Name: flux.ServiceAttrTagRpcProto, Value: proto},
+			flux.Attribute{Name: flux.ServiceAttrTagRpcRetries, Value: "2"})
+		return NewRetryPolicy(flux.BackendService{
+			Method:             http.MethodPost,
+			EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+		})
+	}
+	// 非Http协议默认非幂等，不重试
+	tester.False(newPolicy(flux.ProtoDubbo).Enabled())
+	tester.True(newPolicy(flux.ProtoDubbo, flux.Attribute{Name: ServiceAttrTagIdempotent, Value: true}).Enabled())
+	// Http协议按请求方法判断
+	tester.False(newPolicy(flux.ProtoHttp).Enabled())
+	tester.True(newPolicy(flux.ProtoHttp, flux.Attribute{Name: ServiceAttrTagIdempotent, Value: true}).Enabled())
+	// 聚合和链式调用服务不重试
+	tester.False(newPolicy(flux.ProtoPipeline, flux.Attribute{Name: ServiceAttrTagIdempotent, Value: true}).Enabled())
+	tester.False(newPolicy(flux.ProtoAggregate, flux.Attribute{Name: ServiceAttrTagIdempotent, Value: true}).Enabled())
+}
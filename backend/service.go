@@ -4,10 +4,19 @@ import (
 	"fmt"
 	"github.com/bytepowered/flux"
 	"github.com/bytepowered/flux/ext"
+	"io"
+	"io/ioutil"
 )
 
 func DoExchangeTransport(ctx flux.Context, transport flux.BackendTransport) *flux.ServeError {
-	result, err := transport.InvokeCodec(ctx, ctx.BackendService())
+	service := ctx.BackendService()
+	// 只记录主服务的参数值；链式调用步骤、Filter等解析的同名参数不做记录
+	if v, ok := ctx.GetVariable(flux.VarArgumentRecorder); ok {
+		if recorder, ok := v.(flux.ArgumentRecorder); ok {
+			service.Arguments = flux.RecordArguments(service.Arguments, recorder)
+		}
+	}
+	result, err := doInvokeCodec(ctx, transport, service)
 	if err != nil {
 		return err
 	}
@@ -26,7 +35,7 @@ func DoExchangeTransport(ctx flux.Context, transport flux.BackendTransport) *flu
 	return nil
 }
 
-// DoInvokeCodec 执行后端服务，获取响应结果；
+// DoInvokeCodec 执行后端服务，获取响应结果；按服务声明的重试策略重试；
 func DoInvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
 	rpcProto := service.AttrRpcProto()
 	transport, ok := ext.GetBackendTransport(rpcProto)
@@ -38,5 +47,57 @@ func DoInvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.Backend
 			Internal:   fmt.Errorf("unknown protocol:%s", rpcProto),
 		}
 	}
-	return transport.InvokeCodec(ctx, service)
+	return doInvokeCodec(ctx, transport, service)
+}
+
+// doInvokeCodec 执行后端服务，并按服务使用的信封规则处理响应数据
+func doInvokeCodec(ctx flux.Context, transport flux.BackendTransport, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	resp, serr := DoInvokeCodecWithRetry(ctx, transport, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return DecodeResponseEnvelope(service, resp)
+}
+
+// IsCompositeService 判断服务是否为组合调用其它服务的聚合或链式调用服务；
+// 子服务的调用已经按各自的信封规则和重试策略执行，组合服务自身不再重复处理。
+func IsCompositeService(service flux.BackendService) bool {
+	switch service.AttrRpcProto() {
+	case flux.ProtoAggregate, flux.ProtoPipeline:
+		return true
+	default:
+		return false
+	}
+}
+
+// DecodeResponseBody 将后端服务响应数据解析为可合并的JSON值；无法解析为JSON的文本数据，以字符串返回。
+func DecodeResponseBody(body interface{}) (interface{}, error) {
+	var data []byte
+	switch v := body.(type) {
+	case io.ReadCloser:
+		defer v.Close()
+		bytes, err := ioutil.ReadAll(v)
+		if nil != err {
+			return nil, err
+		}
+		data = bytes
+	case io.Reader:
+		bytes, err := ioutil.ReadAll(v)
+		if nil != err {
+			return nil, err
+		}
+		data = bytes
+	case []byte:
+		data = v
+	default:
+		return body, nil
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var out interface{}
+	if err := ext.JSONUnmarshal(data, &out); nil != err {
+		return string(data), nil
+	}
+	return out, nil
 }
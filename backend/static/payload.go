@@ -0,0 +1,118 @@
+package static
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	encodingGzip = "gzip"
+	extGzip      = ".gz"
+)
+
+var _ flux.WebServePayload = new(FilePayload)
+
+// FilePayload 静态文件响应；写入响应时打开文件，支持ETag/Last-Modified条件请求、Range请求，
+// 以及客户端支持gzip时返回预压缩的 .gz 文件。
+type FilePayload struct {
+	FileSystem   http.FileSystem
+	Name         string
+	CacheControl string
+	Header       http.Header
+}
+
+// ServeWeb 写入静态文件内容
+func (p *FilePayload) ServeWeb(webc flux.WebContext) error {
+	w, err := webc.HttpResponseWriter()
+	if nil != err {
+		return err
+	}
+	r, err := webc.HttpRequest()
+	if nil != err {
+		return err
+	}
+	header := w.Header()
+	// 网关已开启gzip压缩时，响应Header已声明Content-Encoding，不再使用预压缩文件
+	precompressed := "" == header.Get(flux.HeaderContentEncoding) && acceptsGzip(r)
+	for k, vs := range p.Header {
+		switch http.CanonicalHeaderKey(k) {
+		case flux.HeaderContentType, flux.HeaderContentLength:
+			continue
+		}
+		header[k] = vs
+	}
+	if "" != p.CacheControl {
+		header.Set(flux.HeaderCacheControl, p.CacheControl)
+	}
+	// Content-Type按原始文件名识别，避免被识别为gzip文件
+	if ctype := mime.TypeByExtension(path.Ext(p.Name)); "" != ctype {
+		header.Set(flux.HeaderContentType, ctype)
+	}
+	file, info, encoded, err := p.open(precompressed)
+	if nil != err {
+		return err
+	}
+	defer file.Close()
+	if encoded {
+		header.Set(flux.HeaderContentEncoding, encodingGzip)
+		header.Add(flux.HeaderVary, flux.HeaderAcceptEncoding)
+	}
+	header.Set(flux.HeaderETag, NewETag(info, encoded))
+	http.ServeContent(w, r, p.Name, info.ModTime(), file)
+	return nil
+}
+
+// open 打开文件；客户端支持gzip且存在 .gz 文件时，优先打开预压缩文件
+func (p *FilePayload) open(precompressed bool) (http.File, os.FileInfo, bool, error) {
+	if precompressed {
+		if file, info, err := openFile(p.FileSystem, p.Name+extGzip); nil == err {
+			return file, info, true, nil
+		}
+	}
+	file, info, err := openFile(p.FileSystem, p.Name)
+	if nil != err {
+		return nil, nil, false, fmt.Errorf("open static file: %s, err: %w", p.Name, err)
+	}
+	return file, info, false, nil
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get(flux.HeaderAcceptEncoding), ",") {
+		if encodingGzip == strings.TrimSpace(strings.SplitN(v, ";", 2)[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewETag 根据文件大小和修改时间生成强校验的ETag，用于If-Range的Range请求；
+// 预压缩文件与原始文件的内容不同，使用不同的ETag
+func NewETag(info os.FileInfo, encoded bool) string {
+	tag := fmt.Sprintf(`"%x-%x`, info.ModTime().UnixNano(), info.Size())
+	if encoded {
+		tag += "-" + encodingGzip
+	}
+	return tag + `"`
+}
+
+func openFile(fs http.FileSystem, name string) (http.File, os.FileInfo, error) {
+	file, err := fs.Open(name)
+	if nil != err {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if nil != err {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		_ = file.Close()
+		return nil, nil, os.ErrNotExist
+	}
+	return file, info, nil
+}
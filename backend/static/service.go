@@ -0,0 +1,262 @@
+package static
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+const (
+	ConfigKeyCacheControl = "cache_control"
+	ConfigKeyIndexFile    = "index_file"
+)
+
+// BackendService的属性：覆盖Transport的全局配置
+const (
+	// ServiceAttrTagSpaFallback 文件不存在时，返回首页文件，由前端路由处理：[true, false]；默认为false
+	ServiceAttrTagSpaFallback = "spafallback"
+	// ServiceAttrTagCacheControl 静态资源的Cache-Control响应头；首页文件总是使用 no-cache
+	ServiceAttrTagCacheControl = "cachecontrol"
+	// ServiceAttrTagIndexFile 目录的首页文件名
+	ServiceAttrTagIndexFile = "indexfile"
+)
+
+const (
+	// SchemeEmbed 使用 SetFileSystem 注册的文件系统；BackendService.Interface 为文件系统名称
+	SchemeEmbed = "embed"
+)
+
+var (
+	fileSystems = new(sync.Map)
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoStatic, NewBackendTransportService())
+}
+
+// SetFileSystem 注册命名的文件系统，例如打包到程序中的静态资源
+func SetFileSystem(name string, fs http.FileSystem) {
+	fileSystems.Store(name, fs)
+}
+
+// GetFileSystem 获取命名的文件系统
+func GetFileSystem(name string) (http.FileSystem, bool) {
+	v, ok := fileSystems.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(http.FileSystem), true
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+)
+
+// BackendTransportService 在网关中托管静态文件和单页应用的Transport；
+// 静态资源与API一样通过Endpoint声明，Filter执行完成后由 *FilePayload 写入文件内容。
+type BackendTransportService struct {
+	responseCodecFunc flux.BackendResponseCodecFunc
+	cacheControl      string
+	indexFile         string
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith(WithResponseCodecFunc(NewBackendResponseCodecFunc()))
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		cacheControl: "public, max-age=3600",
+		indexFile:    "index.html",
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("Static backend transport initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCacheControl: "public, max-age=3600",
+		ConfigKeyIndexFile:    "index.html",
+	})
+	b.cacheControl = config.GetString(ConfigKeyCacheControl)
+	b.indexFile = config.GetString(ConfigKeyIndexFile)
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.responseCodecFunc(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	return result, nil
+}
+
+// Invoke 查找请求路径对应的静态文件，返回 *FilePayload；文件内容在写入响应时读取
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	if method := ctx.Method(); http.MethodGet != method && http.MethodHead != method {
+		return nil, &flux.ServeError{
+			StatusCode: http.StatusMethodNotAllowed,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageStaticMethodNotAllowed,
+			Header:     http.Header{flux.HeaderAllow: []string{"GET, HEAD"}},
+		}
+	}
+	fs, err := b.lookupFileSystem(service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageStaticFileSystemNotFound,
+			Internal:   err,
+		}
+	}
+	indexFile := b.indexFile
+	if v := service.GetAttr(ServiceAttrTagIndexFile).GetString(); "" != v {
+		indexFile = v
+	}
+	cacheControl := b.cacheControl
+	if v := service.GetAttr(ServiceAttrTagCacheControl).GetString(); "" != v {
+		cacheControl = v
+	}
+	name := RequestFilePath(ctx)
+	file, ok := resolveFile(fs, name, indexFile)
+	if !ok && service.GetAttr(ServiceAttrTagSpaFallback).GetBool() && acceptsFallback(ctx, name) {
+		// 前端路由：不存在的页面路径返回首页文件
+		file, ok = resolveFile(fs, "/"+indexFile, indexFile)
+	}
+	if !ok {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusNotFound,
+			ErrorCode:  flux.ErrorCodeRequestNotFound,
+			Message:    flux.ErrorMessageStaticFileNotFound,
+			Internal:   fmt.Errorf("static file not found: %s", name),
+		}
+	}
+	if path.Base(file) == indexFile {
+		cacheControl = "no-cache"
+	}
+	payload := &FilePayload{
+		FileSystem:   fs,
+		Name:         file,
+		CacheControl: cacheControl,
+	}
+	// Filter设置的响应Header，在写入文件时一并返回
+	if resp := ctx.Response(); nil != resp {
+		payload.Header = resp.HeaderVars()
+	}
+	return payload, nil
+}
+
+func (b *BackendTransportService) lookupFileSystem(service flux.BackendService) (http.FileSystem, error) {
+	if SchemeEmbed == strings.ToLower(service.Scheme) {
+		fs, ok := GetFileSystem(service.Interface)
+		if !ok {
+			return nil, fmt.Errorf("static file-system not registered: %s", service.Interface)
+		}
+		return fs, nil
+	}
+	if "" == service.Interface {
+		return nil, fmt.Errorf("static root directory is empty, service: %s", service.ServiceID())
+	}
+	return http.Dir(service.Interface), nil
+}
+
+// RequestFilePath 返回请求的文件路径；Endpoint使用通配路径（如 /admin/*）时，为通配部分的路径
+func RequestFilePath(ctx flux.Context) string {
+	name := ctx.Request().PathVar("*")
+	if "" == name {
+		if u := ctx.Request().URL(); nil != u && !strings.Contains(ctx.Endpoint().HttpPattern, "*") {
+			name = u.Path
+		}
+	}
+	return path.Clean("/" + name)
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, raw interface{}) (*flux.BackendResponse, error) {
+		payload, ok := raw.(*FilePayload)
+		if !ok {
+			return nil, fmt.Errorf("unknown static backend response: %T", raw)
+		}
+		return &flux.BackendResponse{
+			StatusCode: flux.StatusOK,
+			Headers:    make(http.Header, 0),
+			Body:       payload,
+		}, nil
+	}
+}
+
+// resolveFile 查找文件；目录时查找其首页文件
+func resolveFile(fs http.FileSystem, name, indexFile string) (string, bool) {
+	info, ok := statFile(fs, name)
+	if !ok {
+		return "", false
+	}
+	if !info.IsDir() {
+		return name, true
+	}
+	index := path.Join(name, indexFile)
+	if info, ok := statFile(fs, index); ok && !info.IsDir() {
+		return index, true
+	}
+	return "", false
+}
+
+func statFile(fs http.FileSystem, name string) (os.FileInfo, bool) {
+	f, err := fs.Open(name)
+	if nil != err {
+		return nil, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if nil != err {
+		return nil, false
+	}
+	return info, true
+}
+
+// acceptsFallback 只对页面请求返回首页文件；带扩展名的资源文件不存在时仍返回404
+func acceptsFallback(ctx flux.Context, name string) bool {
+	if "" != path.Ext(name) {
+		return false
+	}
+	accept := ctx.Request().HeaderVar(flux.HeaderAccept)
+	return "" == accept || strings.Contains(accept, "text/html") || strings.Contains(accept, "*/*")
+}
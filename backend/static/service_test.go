@@ -0,0 +1,100 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/webserver"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackendTransportService_ServeFiles(t *testing.T) {
+	tester := assert.New(t)
+	root, err := ioutil.TempDir("", "flux-static")
+	tester.NoError(err)
+	defer os.RemoveAll(root)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte("console.log('app')"))
+	_ = zw.Close()
+	tester.NoError(ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("<html></html>"), 0644))
+	tester.NoError(ioutil.WriteFile(filepath.Join(root, "app.js"), []byte("console.log('app')"), 0644))
+	tester.NoError(ioutil.WriteFile(filepath.Join(root, "app.js.gz"), gz.Bytes(), 0644))
+	transport := NewBackendTransportService()
+	service := flux.BackendService{
+		Interface: root,
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: ServiceAttrTagSpaFallback, Value: true},
+		}},
+	}
+	server := webserver.NewAdaptWebServer(flux.NewConfigurationOfMap(map[string]interface{}{
+		"features": map[string]interface{}{},
+	}))
+	server.AddHandler(http.MethodGet, "/admin/*", func(webc flux.WebContext) error {
+		ctx := context.NewMockContext(map[string]interface{}{
+			"method":          webc.Method(),
+			"url":             webc.URL(),
+			"*":               webc.PathVar("*"),
+			flux.HeaderAccept: webc.HeaderVar(flux.HeaderAccept),
+		})
+		ret, serr := transport.Invoke(ctx, service)
+		if nil != serr {
+			return webc.Write(serr.StatusCode, flux.MIMEApplicationJSON, []byte(serr.Message))
+		}
+		return ret.(flux.WebServePayload).ServeWeb(webc)
+	})
+	gateway := httptest.NewServer(server.Router().(*echo.Echo))
+	defer gateway.Close()
+	get := func(uri string, header map[string]string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, gateway.URL+uri, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		tester.NoError(err)
+		return resp
+	}
+	// 预压缩文件
+	resp := get("/admin/app.js", map[string]string{flux.HeaderAcceptEncoding: "gzip"})
+	tester.Equal(http.StatusOK, resp.StatusCode)
+	tester.Equal("gzip", resp.Header.Get(flux.HeaderContentEncoding))
+	tester.Contains(resp.Header.Get(flux.HeaderContentType), "javascript")
+	tester.Equal("public, max-age=3600", resp.Header.Get(flux.HeaderCacheControl))
+	gzipETag := resp.Header.Get(flux.HeaderETag)
+	// ETag条件请求
+	resp = get("/admin/app.js", map[string]string{flux.HeaderAcceptEncoding: "identity"})
+	tester.Equal(http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get(flux.HeaderETag)
+	tester.NotEmpty(etag)
+	tester.False(strings.HasPrefix(etag, "W/"))
+	tester.NotEqual(gzipETag, etag)
+	resp = get("/admin/app.js", map[string]string{"If-None-Match": etag, flux.HeaderAcceptEncoding: "identity"})
+	tester.Equal(http.StatusNotModified, resp.StatusCode)
+	// Range请求
+	resp = get("/admin/app.js", map[string]string{"Range": "bytes=0-6"})
+	tester.Equal(http.StatusPartialContent, resp.StatusCode)
+	data, _ := ioutil.ReadAll(resp.Body)
+	tester.Equal("console", string(data))
+	// If-Range匹配时返回部分内容；预压缩文件的ETag不匹配原始文件，返回完整内容
+	resp = get("/admin/app.js", map[string]string{"Range": "bytes=0-6", "If-Range": etag})
+	tester.Equal(http.StatusPartialContent, resp.StatusCode)
+	resp = get("/admin/app.js", map[string]string{"Range": "bytes=0-6", "If-Range": gzipETag})
+	tester.Equal(http.StatusOK, resp.StatusCode)
+	// 前端路由返回首页文件；资源文件不存在返回404
+	resp = get("/admin/users/1", map[string]string{flux.HeaderAccept: "text/html"})
+	tester.Equal(http.StatusOK, resp.StatusCode)
+	tester.Equal("no-cache", resp.Header.Get(flux.HeaderCacheControl))
+	data, _ = ioutil.ReadAll(resp.Body)
+	tester.Equal("<html></html>", string(data))
+	resp = get("/admin/missing.js", nil)
+	tester.Equal(http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,328 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/spf13/cast"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ConfigKeyIdleTimeout      = "idle_timeout"
+	ConfigKeyHandshakeTimeout = "handshake_timeout"
+	ConfigKeyMaxConnections   = "max_connections"
+	ConfigKeyTraceEnable      = "trace_enable"
+)
+
+const (
+	// InspectNameConnections 查看WebSocket连接数的Inspect名称
+	InspectNameConnections = "websocket-connections"
+)
+
+var (
+	ErrNotHijackable = errors.New("BACKEND:WS:NOT_HIJACKABLE")
+)
+
+func init() {
+	ext.SetBackendTransport(flux.ProtoWebSocket, NewBackendTransportService())
+}
+
+var _ flux.BackendTransport = new(BackendTransportService)
+
+type (
+	// Option 配置函数
+	Option func(service *BackendTransportService)
+)
+
+// BackendTransportService 代理WebSocket连接的Transport；
+// 在Filter执行完成后，与后端服务完成升级握手，再接管客户端连接并双向转发数据帧。
+type BackendTransportService struct {
+	connections       int64 // 当前连接数；保持64位对齐
+	maxConnections    int64
+	responseCodecFunc flux.BackendResponseCodecFunc
+	idleTimeout       time.Duration
+	handshakeTimeout  time.Duration
+	traceEnable       bool
+}
+
+// WithResponseCodecFunc 用于配置响应数据解析实现函数
+func WithResponseCodecFunc(fun flux.BackendResponseCodecFunc) Option {
+	return func(service *BackendTransportService) {
+		service.responseCodecFunc = fun
+	}
+}
+
+func NewBackendTransportService() *BackendTransportService {
+	return NewBackendTransportServiceWith(WithResponseCodecFunc(NewBackendResponseCodecFunc()))
+}
+
+func NewBackendTransportServiceWith(opts ...Option) *BackendTransportService {
+	bts := &BackendTransportService{
+		idleTimeout:      time.Minute,
+		handshakeTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(bts)
+	}
+	return bts
+}
+
+func (b *BackendTransportService) Init(config *flux.Configuration) error {
+	logger.Info("WebSocket backend transport initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyIdleTimeout:      "60s",
+		ConfigKeyHandshakeTimeout: "10s",
+		ConfigKeyMaxConnections:   1024,
+		ConfigKeyTraceEnable:      false,
+	})
+	b.idleTimeout = config.GetDuration(ConfigKeyIdleTimeout)
+	b.handshakeTimeout = config.GetDuration(ConfigKeyHandshakeTimeout)
+	b.maxConnections = int64(config.GetInt(ConfigKeyMaxConnections))
+	b.traceEnable = config.GetBool(ConfigKeyTraceEnable)
+	ext.SetInspectFunc(InspectNameConnections, func() interface{} {
+		return map[string]interface{}{
+			"connections":     b.Connections(),
+			"max-connections": b.maxConnections,
+		}
+	})
+	return nil
+}
+
+func (b *BackendTransportService) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return b.responseCodecFunc
+}
+
+// Connections 返回当前代理的WebSocket连接数
+func (b *BackendTransportService) Connections() int64 {
+	return atomic.LoadInt64(&b.connections)
+}
+
+func (b *BackendTransportService) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, b)
+}
+
+func (b *BackendTransportService) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	raw, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result, err := b.responseCodecFunc(ctx, raw)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageBackendDecodeResponse,
+			Internal:   err,
+		}
+	}
+	return result, nil
+}
+
+// Invoke 与后端服务完成WebSocket升级握手；握手成功返回 *UpgradedConn，后端服务拒绝升级时返回其 *http.Response
+func (b *BackendTransportService) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	if !IsUpgradeRequest(ctx.Request().HeaderVars()) {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    flux.ErrorMessageWebSocketNotUpgrade,
+		}
+	}
+	if b.maxConnections > 0 && atomic.AddInt64(&b.connections, 1) > b.maxConnections {
+		atomic.AddInt64(&b.connections, -1)
+		return nil, &flux.ServeError{
+			StatusCode: http.StatusServiceUnavailable,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageWebSocketTooManyConnections,
+			Internal:   fmt.Errorf("websocket connections exceed limit: %d", b.maxConnections),
+		}
+	} else if b.maxConnections <= 0 {
+		atomic.AddInt64(&b.connections, 1)
+	}
+	release := func() {
+		atomic.AddInt64(&b.connections, -1)
+	}
+	conn, resp, err := b.handshake(ctx, service)
+	if nil != err {
+		release()
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayBackend,
+			Message:    flux.ErrorMessageWebSocketHandshakeFailed,
+			Internal:   err,
+		}
+	}
+	if http.StatusSwitchingProtocols != resp.StatusCode {
+		release()
+		// 后端服务拒绝升级：按普通Http响应返回，响应数据读取完成后关闭连接
+		resp.Body = &connReadCloser{ReadCloser: resp.Body, conn: conn}
+		return resp, nil
+	}
+	if b.traceEnable {
+		logger.WithContext(ctx).Infow("BACKEND:WEBSOCKET:UPGRADED",
+			"backend-service", service.ServiceID(), "connections", b.Connections())
+	}
+	return &UpgradedConn{
+		Response:    resp,
+		backendConn: conn,
+		idleTimeout: b.idleTimeout,
+		release:     release,
+	}, nil
+}
+
+// handshake 连接后端服务并发送升级请求，读取后端服务的握手响应
+func (b *BackendTransportService) handshake(ctx flux.Context, service flux.BackendService) (net.Conn, *http.Response, error) {
+	target, err := NewTargetURL(service, ctx.Request().URL())
+	if nil != err {
+		return nil, nil, err
+	}
+	goctx, cancel := context.WithTimeout(ctx.Context(), b.handshakeTimeout)
+	defer cancel()
+	conn, err := dialTarget(goctx, target)
+	if nil != err {
+		return nil, nil, fmt.Errorf("dial websocket backend, url: %s, err: %w", target, err)
+	}
+	// 握手超时：设置连接的截止时间以中断读写
+	stop, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-goctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	stopWatch := func() {
+		close(stop)
+		<-exited
+	}
+	header := ctx.Request().HeaderVars().Clone()
+	if nil == header {
+		header = make(http.Header)
+	}
+	for k, v := range ctx.Attributes() {
+		header.Set(k, cast.ToString(v))
+	}
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        target,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       target.Host,
+	}
+	if err := request.Write(conn); nil != err {
+		stopWatch()
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("write websocket handshake, url: %s, err: %w", target, err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, request)
+	stopWatch()
+	if nil != err {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("read websocket handshake, url: %s, err: %w", target, err)
+	}
+	if err := conn.SetDeadline(time.Time{}); nil != err {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	// 握手响应之后的数据帧，可能已被读取到缓冲区中
+	return &bufferedConn{Conn: conn, reader: reader}, resp, nil
+}
+
+// NewTargetURL 根据BackendService的RemoteHost和Interface，以及请求的Query参数，构建后端服务地址
+func NewTargetURL(service flux.BackendService, inURL *url.URL) (*url.URL, error) {
+	scheme := strings.ToLower(service.Scheme)
+	switch scheme {
+	case "", "ws", "http":
+		scheme = "ws"
+	case "wss", "https":
+		scheme = "wss"
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme: %s", service.Scheme)
+	}
+	if "" == service.RemoteHost {
+		return nil, errors.New("websocket backend remote-host is empty")
+	}
+	target := &url.URL{Scheme: scheme, Host: service.RemoteHost, Path: service.Interface}
+	if nil != inURL {
+		target.RawQuery = inURL.RawQuery
+	}
+	return target, nil
+}
+
+// IsUpgradeRequest 判断请求是否为WebSocket升级请求
+func IsUpgradeRequest(header http.Header) bool {
+	return headerContainsToken(header, "Connection", "upgrade") &&
+		headerContainsToken(header, "Upgrade", "websocket")
+}
+
+func NewBackendResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return func(ctx flux.Context, raw interface{}) (*flux.BackendResponse, error) {
+		switch v := raw.(type) {
+		case *UpgradedConn:
+			return &flux.BackendResponse{
+				StatusCode: http.StatusSwitchingProtocols,
+				Headers:    make(http.Header, 0),
+				Body:       v,
+			}, nil
+		case *http.Response:
+			return &flux.BackendResponse{
+				StatusCode: v.StatusCode,
+				Headers:    v.Header,
+				Body:       v.Body,
+			}, nil
+		default:
+			return nil, fmt.Errorf("unknown websocket backend response: %T", raw)
+		}
+	}
+}
+
+func dialTarget(ctx context.Context, target *url.URL) (net.Conn, error) {
+	host := target.Host
+	if "" == target.Port() {
+		if "wss" == target.Scheme {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if nil != err || "wss" != target.Scheme {
+		return conn, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: target.Hostname()})
+	if err := tlsConn.Handshake(); nil != err {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
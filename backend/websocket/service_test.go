@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"bufio"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/webserver"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackendTransportService_Proxy(t *testing.T) {
+	tester := assert.New(t)
+	// 后端服务：完成升级握手后，回写收到的数据
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsUpgradeRequest(r.Header) || "/chat" != r.URL.Path || "acme" != r.URL.Query().Get("room") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		conn, rw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		_, _ = io.Copy(conn, rw)
+	}))
+	defer backendServer.Close()
+	transport := NewBackendTransportServiceWith(WithResponseCodecFunc(NewBackendResponseCodecFunc()))
+	transport.maxConnections = 1
+	service := flux.BackendService{
+		Scheme:     "ws",
+		RemoteHost: strings.TrimPrefix(backendServer.URL, "http://"),
+		Interface:  "/chat",
+	}
+	// 网关服务
+	server := webserver.NewAdaptWebServer(flux.NewConfigurationOfMap(map[string]interface{}{
+		"features": map[string]interface{}{},
+	}))
+	server.AddHandler(http.MethodGet, "/ws", func(webc flux.WebContext) error {
+		ctx := context.NewMockContext(map[string]interface{}{
+			"header-values": webc.HeaderVars(),
+			"url":           webc.URL(),
+		})
+		ret, serr := transport.Invoke(ctx, service)
+		if nil != serr {
+			return webc.Write(serr.StatusCode, flux.MIMEApplicationJSON, []byte(serr.Message))
+		}
+		return ret.(flux.WebServePayload).ServeWeb(webc)
+	})
+	gatewayServer := httptest.NewServer(server.Router().(*echo.Echo))
+	defer gatewayServer.Close()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(gatewayServer.URL, "http://"))
+	tester.NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /ws?room=acme HTTP/1.1\r\nHost: gateway\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+	tester.NoError(err)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	tester.NoError(err)
+	tester.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	_, err = conn.Write([]byte("hello"))
+	tester.NoError(err)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(reader, buf)
+	tester.NoError(err)
+	tester.Equal("hello", string(buf))
+	tester.Equal(int64(1), transport.Connections())
+	// 超出连接数限制
+	resp, err = http.Get(gatewayServer.URL + "/ws?room=acme")
+	tester.NoError(err)
+	tester.Equal(http.StatusBadRequest, resp.StatusCode)
+	req, _ := http.NewRequest(http.MethodGet, gatewayServer.URL+"/ws?room=acme", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	resp, err = http.DefaultClient.Do(req)
+	tester.NoError(err)
+	tester.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	// 客户端关闭连接后释放连接数
+	_ = conn.Close()
+	for i := 0; i < 100 && transport.Connections() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tester.Equal(int64(0), transport.Connections())
+}
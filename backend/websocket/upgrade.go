@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ flux.WebServePayload = new(UpgradedConn)
+
+// UpgradedConn 已与后端服务完成升级握手的WebSocket连接；
+// 作为响应数据写入时，向客户端返回握手响应，接管客户端连接并双向转发数据帧。
+type UpgradedConn struct {
+	lastActive  int64 // 最后活跃时间；保持64位对齐
+	Response    *http.Response
+	backendConn net.Conn
+	idleTimeout time.Duration
+	release     func()
+	closeOnce   sync.Once
+}
+
+// ServeWeb 接管客户端连接，双向转发数据帧，直到任意一端关闭连接或空闲超时
+func (u *UpgradedConn) ServeWeb(webc flux.WebContext) error {
+	defer u.Close()
+	w, err := webc.HttpResponseWriter()
+	if nil != err {
+		return err
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return ErrNotHijackable
+	}
+	clientConn, clientRW, err := hijacker.Hijack()
+	if nil != err {
+		return fmt.Errorf("hijack websocket client connection: %w", err)
+	}
+	defer clientConn.Close()
+	if err := u.Response.Write(clientRW); nil != err {
+		return fmt.Errorf("write websocket handshake to client: %w", err)
+	}
+	if err := clientRW.Flush(); nil != err {
+		return fmt.Errorf("write websocket handshake to client: %w", err)
+	}
+	u.touch()
+	errc := make(chan error, 2)
+	go u.pipe(u.backendConn, clientRW.Reader, errc)
+	go u.pipe(clientConn, u.backendConn, errc)
+	var idle <-chan time.Time
+	if u.idleTimeout > 0 {
+		ticker := time.NewTicker(u.idleTimeout / 2)
+		defer ticker.Stop()
+		idle = ticker.C
+	}
+	for {
+		select {
+		case err := <-errc:
+			// 任意一端关闭连接，关闭另一端连接
+			if io.EOF == err {
+				return nil
+			}
+			return err
+		case <-idle:
+			if time.Since(time.Unix(0, atomic.LoadInt64(&u.lastActive))) > u.idleTimeout {
+				return nil
+			}
+		}
+	}
+}
+
+// Close 关闭后端服务连接，释放连接数
+func (u *UpgradedConn) Close() error {
+	var err error
+	u.closeOnce.Do(func() {
+		err = u.backendConn.Close()
+		if nil != u.release {
+			u.release()
+		}
+	})
+	return err
+}
+
+func (u *UpgradedConn) pipe(dst io.Writer, src io.Reader, errc chan<- error) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			u.touch()
+			if _, werr := dst.Write(buf[:n]); nil != werr {
+				errc <- werr
+				return
+			}
+		}
+		if nil != rerr {
+			errc <- rerr
+			return
+		}
+	}
+}
+
+func (u *UpgradedConn) touch() {
+	atomic.StoreInt64(&u.lastActive, time.Now().UnixNano())
+}
+
+// bufferedConn 读取握手响应后，优先读取缓冲区中的数据
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// connReadCloser 响应数据读取完成后，关闭后端服务连接
+type connReadCloser struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (c *connReadCloser) Close() error {
+	err := c.ReadCloser.Close()
+	_ = c.conn.Close()
+	return err
+}
@@ -0,0 +1,63 @@
+package boot
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
+	"io"
+	"time"
+)
+
+const (
+	// ConfigKeyEndpointTimeout 全局默认的Endpoint请求总超时时间，Endpoint属性 timeout 优先
+	ConfigKeyEndpointTimeout = "endpoint_timeout"
+)
+
+// endpointTimeout 返回Endpoint请求的总超时时间；未声明或者声明非法时，使用全局默认值
+func endpointTimeout(ctx flux.Context, defaults time.Duration) time.Duration {
+	to := ctx.Endpoint().AttrTimeout()
+	if "" == to {
+		return defaults
+	}
+	timeout, err := time.ParseDuration(to)
+	if nil != err || timeout <= 0 {
+		logger.WithContext(ctx).Warnw("Illegal endpoint timeout", "timeout", to)
+		return defaults
+	}
+	return timeout
+}
+
+// withDeadline 设置请求截止时间；超时时间为0时，不限制截止时间
+func withDeadline(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// isDeadlineExceeded 判断请求是否因为截止时间到达而失败
+func isDeadlineExceeded(ctx context.Context, err *flux.ServeError) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err.Internal, context.DeadlineExceeded)
+}
+
+func newDeadlineServeError(err *flux.ServeError) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusTimeout,
+		ErrorCode:  flux.ErrorCodeGatewayTimeout,
+		Message:    flux.ErrorMessageRouteDeadlineExceeded,
+		Header:     err.Header,
+		Internal:   err,
+	}
+}
+
+// deadlineReadCloser 响应数据流被关闭时，释放请求截止时间的Context
+type deadlineReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *deadlineReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
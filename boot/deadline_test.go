@@ -0,0 +1,56 @@
+package boot
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const protoTestBlocking = "TEST_BLOCKING"
+
+type blockingTransport struct{}
+
+func (b *blockingTransport) Exchange(ctx flux.Context) *flux.ServeError {
+	_, err := b.Invoke(ctx, ctx.BackendService())
+	return err
+}
+
+func (b *blockingTransport) Invoke(ctx flux.Context, _ flux.BackendService) (interface{}, *flux.ServeError) {
+	<-ctx.Context().Done()
+	return nil, &flux.ServeError{
+		StatusCode: flux.StatusBadGateway,
+		ErrorCode:  flux.ErrorCodeGatewayBackend,
+		Internal:   ctx.Context().Err(),
+	}
+}
+
+func (b *blockingTransport) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	_, err := b.Invoke(ctx, service)
+	return nil, err
+}
+
+func (b *blockingTransport) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return nil
+}
+
+func TestRouter_RouteDeadline(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetBackendTransport(protoTestBlocking, new(blockingTransport))
+	router := &Router{metrics: NewMetrics(), timeout: time.Millisecond * 20}
+	ctx := context.NewMockContext(map[string]interface{}{
+		"service": flux.BackendService{
+			EmbeddedAttributes: flux.EmbeddedAttributes{
+				Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: protoTestBlocking}},
+			},
+		},
+	})
+	start := time.Now()
+	err := router.Route(ctx)
+	tester.NotNil(err)
+	tester.Equal(flux.StatusTimeout, err.StatusCode)
+	tester.Equal(flux.ErrorCodeGatewayTimeout, err.ErrorCode)
+	tester.True(time.Since(start) < time.Second)
+}
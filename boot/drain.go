@@ -0,0 +1,132 @@
+package boot
+
+import (
+	"context"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/logger"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ConfigKeyShutdownDrainDelay 停止服务时，标记未就绪后等待负载均衡摘除流量的时间
+	ConfigKeyShutdownDrainDelay = "shutdown_drain_delay"
+)
+
+const (
+	readinessStatusUp       = "UP"
+	readinessStatusDraining = "DRAINING"
+)
+
+// InflightRequest 正在执行的路由请求
+type InflightRequest struct {
+	ServerId  string
+	RequestId string
+	StartAt   time.Time
+}
+
+// InflightTracker 按ListenServer记录正在执行的路由请求；
+// 停止服务时，用于等待正在执行的请求完成，并记录超时未完成的请求。
+type InflightTracker struct {
+	draining int32
+	count    int64
+	requests sync.Map // *InflightRequest -> struct{}
+}
+
+func NewInflightTracker() *InflightTracker {
+	return &InflightTracker{}
+}
+
+// Enter 记录开始执行的路由请求
+func (t *InflightTracker) Enter(serverId, requestId string) *InflightRequest {
+	req := &InflightRequest{ServerId: serverId, RequestId: requestId, StartAt: time.Now()}
+	t.requests.Store(req, struct{}{})
+	atomic.AddInt64(&t.count, 1)
+	return req
+}
+
+// Leave 移除执行完成的路由请求
+func (t *InflightTracker) Leave(req *InflightRequest) {
+	t.requests.Delete(req)
+	atomic.AddInt64(&t.count, -1)
+}
+
+// Count 返回正在执行的请求总数
+func (t *InflightTracker) Count() int64 {
+	return atomic.LoadInt64(&t.count)
+}
+
+// Counts 返回各ListenServer正在执行的请求数
+func (t *InflightTracker) Counts() map[string]int {
+	out := make(map[string]int, 2)
+	t.Range(func(req *InflightRequest) bool {
+		out[req.ServerId]++
+		return true
+	})
+	return out
+}
+
+// Range 遍历正在执行的请求
+func (t *InflightTracker) Range(f func(req *InflightRequest) bool) {
+	t.requests.Range(func(k, _ interface{}) bool {
+		return f(k.(*InflightRequest))
+	})
+}
+
+// SetDraining 标记服务进入停止状态，就绪检查返回失败
+func (t *InflightTracker) SetDraining() {
+	atomic.StoreInt32(&t.draining, 1)
+}
+
+// IsDraining 返回服务是否处于停止状态
+func (t *InflightTracker) IsDraining() bool {
+	return atomic.LoadInt32(&t.draining) == 1
+}
+
+// Wait 等待正在执行的请求完成；Context超时时，返回Context的错误
+func (t *InflightTracker) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(time.Millisecond * 50)
+	defer ticker.Stop()
+	for t.Count() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// ReadinessHandler 就绪检查接口；服务停止时返回503，以便负载均衡摘除流量
+func (t *InflightTracker) ReadinessHandler(webc flux.WebContext) error {
+	status, code := readinessStatusUp, flux.StatusOK
+	if t.IsDraining() {
+		status, code = readinessStatusDraining, http.StatusServiceUnavailable
+	}
+	return webc.Send(webc, http.Header{}, code, map[string]interface{}{
+		"status":   status,
+		"inflight": t.Counts(),
+	})
+}
+
+// drain 标记未就绪，等待负载均衡摘除流量后，等待正在执行的请求完成；超时未完成的请求被放弃并记录日志
+func (t *InflightTracker) drain(ctx context.Context, delay time.Duration) {
+	t.SetDraining()
+	if delay > 0 {
+		logger.Infow("Server draining, waiting for load balancers", "drain-delay", delay.String())
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+	logger.Infow("Server draining, waiting for inflight requests", "inflight", t.Counts())
+	if err := t.Wait(ctx); nil != err {
+		t.Range(func(req *InflightRequest) bool {
+			logger.Warnw("SERVER:SHUTDOWN:ABANDON_INFLIGHT",
+				"server-id", req.ServerId, "request-id", req.RequestId, "elapses", time.Since(req.StartAt).String())
+			return true
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package boot
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestInflightTracker_Wait(t *testing.T) {
+	tester := assert.New(t)
+	tracker := NewInflightTracker()
+	a := tracker.Enter(ListenServerIdDefault, "req-a")
+	b := tracker.Enter(ListenServerIdAdmin, "req-b")
+	tester.Equal(int64(2), tracker.Count())
+	tester.Equal(map[string]int{ListenServerIdDefault: 1, ListenServerIdAdmin: 1}, tracker.Counts())
+	tracker.Leave(b)
+	// 超时未完成
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*60)
+	tester.Equal(context.DeadlineExceeded, tracker.Wait(ctx))
+	cancel()
+	// 等待完成
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		tracker.Leave(a)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tester.NoError(tracker.Wait(ctx))
+	tester.Equal(int64(0), tracker.Count())
+	tester.False(tracker.IsDraining())
+	tracker.SetDraining()
+	tester.True(tracker.IsDraining())
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/bytepowered/flux/backend"
 	"github.com/bytepowered/flux/discovery"
 	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/filter"
 	"github.com/bytepowered/flux/logger"
 )
 
@@ -21,4 +22,6 @@ func init() {
 	// Endpoint discovery
 	ext.SetEndpointDiscovery(discovery.NewZookeeperServiceWith(discovery.ZookeeperId))
 	ext.SetEndpointDiscovery(discovery.NewResourceServiceWith(discovery.ResourceId))
+	// Dynamic filter factories
+	ext.SetFactory(filter.TypeIdJwtVerificationFilter, filter.JwtVerificationFilterFactory)
 }
@@ -33,6 +33,12 @@ type Metrics struct {
 	EndpointAccess *prometheus.CounterVec
 	EndpointError  *prometheus.CounterVec
 	RouteDuration  *prometheus.HistogramVec
+	// 流量镜像
+	MirrorTotal       *prometheus.CounterVec
+	MirrorStatusDiff  *prometheus.CounterVec
+	MirrorLatencyDiff *prometheus.HistogramVec
+	// 后端服务重试
+	BackendRetry *prometheus.CounterVec
 }
 
 func NewMetrics() *Metrics {
@@ -56,5 +62,30 @@ func NewMetrics() *Metrics {
 			Help:      "Spend time by processing a endpoint",
 			Buckets:   defaultMetricBuckets,
 		}, []string{"ComponentType", "TypeId"}),
+		MirrorTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "endpoint_mirror_total",
+			Help:      "Number of endpoint mirror requests",
+		}, []string{"ServiceId", "Result"}),
+		MirrorStatusDiff: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "endpoint_mirror_status_diff_total",
+			Help:      "Number of endpoint mirror responses with different status code",
+		}, []string{"ServiceId", "PrimaryStatus", "MirrorStatus"}),
+		MirrorLatencyDiff: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "endpoint_mirror_latency_diff",
+			Help:      "Latency difference between mirror and primary service, in seconds",
+			Buckets:   []float64{-5.0, -1.0, -0.5, -0.1, -0.05, -0.01, 0, 0.01, 0.05, 0.1, 0.5, 1.0, 5.0},
+		}, []string{"ServiceId"}),
+		BackendRetry: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "backend_retry_total",
+			Help:      "Number of backend service retries",
+		}, []string{"ProtoName", "Interface", "Method", "Reason"}),
 	}
 }
@@ -0,0 +1,240 @@
+package boot
+
+import (
+	goctx "context"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// EndpointAttrTagMirror 流量镜像的目标服务ID
+	EndpointAttrTagMirror = "mirror"
+	// EndpointAttrTagMirrorPercent 流量镜像的采样百分比：[0, 100]
+	EndpointAttrTagMirrorPercent = "mirrorpercent"
+)
+
+const (
+	// ConfigKeyEndpointMirror 流量镜像的全局配置
+	ConfigKeyEndpointMirror = "endpoint_mirror"
+
+	ConfigKeyMirrorConcurrency = "concurrency"
+	ConfigKeyMirrorTimeout     = "timeout"
+	ConfigKeyMirrorPercent     = "percent"
+)
+
+const (
+	// varMirrorSnapshot 镜像调用复制请求数据完成的通知
+	varMirrorSnapshot = "flux.mirror.snapshot"
+)
+
+const (
+	mirrorResultSuccess = "success"
+	mirrorResultError   = "error"
+	mirrorResultDropped = "dropped"
+)
+
+// Mirror 将请求异步地镜像到Endpoint声明的镜像服务，丢弃镜像服务的响应结果，只记录与主服务的状态码和耗时差异。
+// 镜像调用的并发数受限，超出并发限制时直接放弃镜像；复制请求数据和镜像调用均在镜像协程中执行，不阻塞主流程。
+type Mirror struct {
+	semaphore chan struct{}
+	timeout   time.Duration
+	percent   int
+	metrics   *Metrics
+}
+
+func NewMirror(config *flux.Configuration, metrics *Metrics) *Mirror {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyMirrorConcurrency: 16,
+		ConfigKeyMirrorTimeout:     "5s",
+		ConfigKeyMirrorPercent:     100,
+	})
+	concurrency := config.GetInt(ConfigKeyMirrorConcurrency)
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Mirror{
+		semaphore: make(chan struct{}, concurrency),
+		timeout:   config.GetDuration(ConfigKeyMirrorTimeout),
+		percent:   config.GetInt(ConfigKeyMirrorPercent),
+		metrics:   metrics,
+	}
+}
+
+// MirrorTask 已通过采样和并发检查的镜像调用
+type MirrorTask struct {
+	mirror    *Mirror
+	serviceId string
+	service   flux.BackendService
+	recorder  *argumentRecorder
+}
+
+// Prepare 按Endpoint的镜像配置，执行采样和并发检查；不需要镜像时返回nil。
+// 必须在主服务调用之前执行：镜像调用使用主服务调用实际解析的参数值。
+func (m *Mirror) Prepare(ctx flux.Context) *MirrorTask {
+	endpoint := ctx.Endpoint()
+	serviceId := endpoint.GetAttr(EndpointAttrTagMirror).GetString()
+	if "" == serviceId {
+		return nil
+	}
+	percent := m.percent
+	if attr := endpoint.GetAttr(EndpointAttrTagMirrorPercent); nil != attr.Value {
+		percent = attr.GetInt()
+	}
+	if percent <= 0 || (percent < 100 && rand.Intn(100) >= percent) {
+		return nil
+	}
+	service, ok := ext.GetBackendService(serviceId)
+	if !ok {
+		logger.WithContext(ctx).Warnw("SERVER:MIRROR:SERVICE_NOT_FOUND", "mirror-service-id", serviceId)
+		m.metrics.MirrorTotal.WithLabelValues(serviceId, mirrorResultError).Inc()
+		return nil
+	}
+	select {
+	case m.semaphore <- struct{}{}:
+	default:
+		m.metrics.MirrorTotal.WithLabelValues(serviceId, mirrorResultDropped).Inc()
+		return nil
+	}
+	recorder := &argumentRecorder{values: make(map[string]interface{}, len(ctx.BackendService().Arguments))}
+	ctx.SetVariable(flux.VarArgumentRecorder, recorder)
+	return &MirrorTask{mirror: m, serviceId: serviceId, service: service, recorder: recorder}
+}
+
+// Submit 异步执行镜像调用；复制请求数据在镜像协程中执行，Context释放前通过 AwaitMirrorSnapshot 等待复制完成。
+func (t *MirrorTask) Submit(ctx flux.Context, primaryStatus int, primaryElapsed time.Duration) {
+	m := t.mirror
+	requestId := ctx.RequestId()
+	done := make(chan struct{})
+	ctx.SetVariable(varMirrorSnapshot, done)
+	go func() {
+		defer func() {
+			<-m.semaphore
+		}()
+		timeout, cancel := goctx.WithTimeout(goctx.Background(), m.timeout)
+		defer cancel()
+		snapshot, arguments, err := t.snapshot(ctx, timeout, done)
+		if nil != err {
+			logger.With(requestId).Warnw("SERVER:MIRROR:SNAPSHOT", "mirror-service-id", t.serviceId, "error", err)
+			m.metrics.MirrorTotal.WithLabelValues(t.serviceId, mirrorResultError).Inc()
+			return
+		}
+		// 使用与主服务相同的参数值
+		service := t.service
+		service.Arguments, err = t.recorder.arguments(arguments, snapshot)
+		if nil != err {
+			logger.WithContext(snapshot).Warnw("SERVER:MIRROR:RESOLVE_ARGUMENTS", "mirror-service-id", t.serviceId, "error", err)
+			m.metrics.MirrorTotal.WithLabelValues(t.serviceId, mirrorResultError).Inc()
+			return
+		}
+		m.invoke(snapshot, service, t.serviceId, primaryStatus, primaryElapsed)
+	}()
+}
+
+// snapshot 复制请求数据；完成后通知Context可以被释放
+func (t *MirrorTask) snapshot(ctx flux.Context, timeout goctx.Context, done chan struct{}) (snapshot *context.SnapshotContext, arguments []flux.Argument, err error) {
+	defer close(done)
+	defer func() {
+		if r := recover(); nil != r {
+			err = fmt.Errorf("snapshot context panic: %v", r)
+		}
+	}()
+	snapshot, err = context.NewSnapshotContext(ctx, timeout)
+	return snapshot, ctx.BackendService().Arguments, err
+}
+
+// AwaitMirrorSnapshot 等待镜像调用完成请求数据的复制；必须在Context被释放前调用。
+func AwaitMirrorSnapshot(ctx flux.Context) {
+	if v, ok := ctx.GetVariable(varMirrorSnapshot); ok {
+		if done, ok := v.(chan struct{}); ok {
+			<-done
+		}
+	}
+}
+
+func (m *Mirror) invoke(ctx flux.Context, service flux.BackendService, serviceId string, primaryStatus int, primaryElapsed time.Duration) {
+	defer func() {
+		if r := recover(); nil != r {
+			logger.WithContext(ctx).Errorw("SERVER:MIRROR:PANIC", "mirror-service-id", serviceId, "recover", r)
+		}
+	}()
+	transport, ok := ext.GetBackendTransport(service.AttrRpcProto())
+	if !ok {
+		logger.WithContext(ctx).Warnw("SERVER:MIRROR:UNSUPPORTED_PROTOCOL", "mirror-service-id", serviceId, "proto", service.AttrRpcProto())
+		m.metrics.MirrorTotal.WithLabelValues(serviceId, mirrorResultError).Inc()
+		return
+	}
+	// 镜像调用不重试，避免放大镜像流量；按信封规则处理响应，与主服务的状态码保持可比
+	start := time.Now()
+	resp, serr := transport.InvokeCodec(ctx, service)
+	if nil == serr {
+		resp, serr = backend.DecodeResponseEnvelope(service, resp)
+	}
+	elapsed := time.Since(start)
+	var status int
+	if nil != serr {
+		status = serr.StatusCode
+		m.metrics.MirrorTotal.WithLabelValues(serviceId, mirrorResultError).Inc()
+	} else {
+		status = resp.StatusCode
+		// 丢弃响应数据
+		if closer, ok := resp.Body.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		m.metrics.MirrorTotal.WithLabelValues(serviceId, mirrorResultSuccess).Inc()
+	}
+	m.metrics.MirrorLatencyDiff.WithLabelValues(serviceId).Observe((elapsed - primaryElapsed).Seconds())
+	if status != primaryStatus {
+		m.metrics.MirrorStatusDiff.WithLabelValues(serviceId, strconv.Itoa(primaryStatus), strconv.Itoa(status)).Inc()
+	}
+}
+
+// argumentRecorder 记录主服务调用实际解析的参数值；同名参数以第一次解析的值为准
+type argumentRecorder struct {
+	mutex  sync.Mutex
+	values map[string]interface{}
+}
+
+func (r *argumentRecorder) Record(name string, value interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.values[name]; !ok {
+		r.values[name] = value
+	}
+}
+
+// arguments 生成使用固定参数值的参数列表；主服务调用没有解析的参数，从复制的请求数据中解析
+func (r *argumentRecorder) arguments(arguments []flux.Argument, ctx flux.Context) ([]flux.Argument, error) {
+	out := make([]flux.Argument, len(arguments))
+	for i, arg := range arguments {
+		r.mutex.Lock()
+		value, ok := r.values[arg.Name]
+		r.mutex.Unlock()
+		if !ok {
+			resolved, err := arg.Resolve(ctx)
+			if nil != err {
+				return nil, err
+			}
+			value = resolved
+		}
+		fixed := arg
+		fixed.Fields = nil
+		fixed.LookupFunc = nil
+		fixed.ValueLoader = func() flux.MTValue {
+			return flux.WrapObjectMTValue(value)
+		}
+		fixed.ValueResolver = func(mtv flux.MTValue, _ string, _ []string) (interface{}, error) {
+			return mtv.Value, nil
+		}
+		out[i] = fixed
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package boot
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+// resolveTransport 测试使用的Transport：解析服务的全部参数
+type resolveTransport struct{}
+
+func (t *resolveTransport) Exchange(ctx flux.Context) *flux.ServeError {
+	return backend.DoExchangeTransport(ctx, t)
+}
+
+func (t *resolveTransport) Invoke(ctx flux.Context, service flux.BackendService) (interface{}, *flux.ServeError) {
+	values := make(map[string]interface{}, len(service.Arguments))
+	for _, arg := range service.Arguments {
+		value, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, &flux.ServeError{StatusCode: flux.StatusServerError, Internal: err}
+		}
+		values[arg.Name] = value
+	}
+	return values, nil
+}
+
+func (t *resolveTransport) InvokeCodec(ctx flux.Context, service flux.BackendService) (*flux.BackendResponse, *flux.ServeError) {
+	values, serr := t.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return &flux.BackendResponse{StatusCode: http.StatusOK, Headers: make(http.Header), Body: values}, nil
+}
+
+func (t *resolveTransport) GetResponseCodecFunc() flux.BackendResponseCodecFunc {
+	return nil
+}
+
+type fluxContext = flux.Context
+
+// responseContext 测试使用的Context：MockContext没有实现响应对象
+type responseContext struct {
+	fluxContext
+	response flux.Response
+}
+
+func (c *responseContext) Response() flux.Response {
+	return c.response
+}
+
+func TestArgumentRecorder_Arguments(t *testing.T) {
+	tester := assert.New(t)
+	ext.SetArgumentLookupFunc(backend.DefaultArgumentLookupFunc)
+	arguments := []flux.Argument{ext.NewStringArgument("uid"), ext.NewStringArgument("name")}
+	recorder := &argumentRecorder{values: make(map[string]interface{})}
+	primary := &responseContext{
+		fluxContext: context.NewMockContext(map[string]interface{}{
+			"uid":     "u1",
+			"name":    "n1",
+			"service": flux.BackendService{Arguments: arguments[:1]},
+		}),
+		response: context.NewDefaultResponse(),
+	}
+	primary.SetVariable(flux.VarArgumentRecorder, recorder)
+	// 主服务调用之外解析的同名参数，不做记录
+	value, err := ext.NewStringArgumentWith("uid", "filter-uid").Resolve(primary)
+	tester.NoError(err)
+	tester.Equal("filter-uid", value)
+	// 主服务调用只解析了uid参数
+	tester.Nil(new(resolveTransport).Exchange(primary))
+	// 镜像调用：uid使用主服务实际解析的值，name从复制的请求数据中解析
+	snapshot := context.NewMockContext(map[string]interface{}{"uid": "u2", "name": "n2"})
+	fixed, err := recorder.arguments(arguments, snapshot)
+	tester.NoError(err)
+	tester.Len(fixed, 2)
+	for i, expected := range []string{"u1", "n2"} {
+		value, err := fixed[i].Resolve(snapshot)
+		tester.NoError(err)
+		tester.Equal(expected, value)
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
 	"github.com/bytepowered/flux/ext"
 	"github.com/bytepowered/flux/logger"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"io"
 	"reflect"
 	"sort"
 	"time"
@@ -14,6 +17,8 @@ import (
 
 type Router struct {
 	metrics *Metrics
+	mirror  *Mirror
+	timeout time.Duration
 	hooks   []flux.PrepareHookFunc
 }
 
@@ -36,6 +41,13 @@ func (r *Router) Prepare() error {
 
 func (r *Router) Initial() error {
 	logger.Info("Router initialing")
+	// Deadline
+	viper.SetDefault(ConfigKeyEndpointTimeout, "10s")
+	r.timeout = viper.GetDuration(ConfigKeyEndpointTimeout)
+	// Traffic mirror
+	r.mirror = NewMirror(flux.NewConfigurationOfNS(ConfigKeyEndpointMirror), r.metrics)
+	// Backend retry
+	backend.SetRetryCounter(r.metrics.BackendRetry)
 	// Backends
 	for proto, backend := range ext.GetBackendTransports() {
 		ns := flux.NamespaceBackendTransports + "." + proto
@@ -124,6 +136,9 @@ func (r *Router) Route(ctx flux.Context) *flux.ServeError {
 	defer func() {
 		ctx.AddMetric("M-Route", time.Since(ctx.StartAt()))
 	}()
+	// Deadline: 请求截止时间对所有Filter和后端服务生效
+	deadline, cancel := withDeadline(ctx.Context(), endpointTimeout(ctx, r.timeout))
+	ctx.SetContext(deadline)
 	// Select filters
 	selective := make([]flux.Filter, 0, 16)
 	for _, selector := range ext.GetSelectors() {
@@ -149,11 +164,34 @@ func (r *Router) Route(ctx flux.Context) *flux.ServeError {
 		} else {
 			// Backend exchange
 			timer := prometheus.NewTimer(r.metrics.RouteDuration.WithLabelValues("BackendTransport", protoName))
+			var mirror *MirrorTask
+			if nil != r.mirror {
+				mirror = r.mirror.Prepare(ctx)
+			}
+			start := time.Now()
 			ret := backend.Exchange(ctx)
 			timer.ObserveDuration()
+			if nil != mirror {
+				status := ctx.Response().StatusCode()
+				if nil != ret {
+					status = ret.StatusCode
+				}
+				mirror.Submit(ctx, status, time.Since(start))
+			}
 			return ret
 		}
 	}, filters)(ctx)
+	if nil != err {
+		cancel()
+		if isDeadlineExceeded(deadline, err) {
+			err = newDeadlineServeError(err)
+		}
+	} else if body, ok := ctx.Response().Payload().(io.ReadCloser); ok {
+		// 响应数据流在写入后关闭，截止时间的Context需要保持到响应写入完成
+		ctx.Response().SetPayload(&deadlineReadCloser{ReadCloser: body, cancel: cancel})
+	} else {
+		cancel()
+	}
 	return doMetricEndpointFunc(err)
 }
 
@@ -0,0 +1,147 @@
+package boot
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/pkg"
+	"github.com/bytepowered/flux/webserver"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ConfigKeyEndpointRouteRules 全局的版本选择规则配置，对所有Endpoint生效，优先级低于Endpoint属性声明的规则
+	ConfigKeyEndpointRouteRules = "endpoint_route_rules"
+)
+
+const (
+	ruleOpEqual    = "=="
+	ruleOpNotEqual = "!="
+	ruleOpMatch    = "=~"
+	ruleOpIn       = "in"
+)
+
+// EndpointRouteRule 声明式的Endpoint版本选择规则。规则格式：
+//
+//	{lookup-expr} {op} {value} [&& {lookup-expr} {op} {value}...] -> {version}
+//
+// 其中lookup-expr为Scope:Key格式，支持域：[header, cookie, query, form, path, param, request]；
+// op支持：==, !=, =~（正则匹配）, in（CIDR匹配，多个CIDR以逗号分隔）。例如：
+//
+//	cookie:beta == true -> 2.0
+//	header:X-Tenant == acme && request:address in 10.0.0.0/8,192.168.0.0/16 -> 2.0
+type EndpointRouteRule struct {
+	Expr       string
+	Version    string
+	conditions []ruleCondition
+}
+
+type ruleCondition struct {
+	scope  string
+	key    string
+	op     string
+	value  string
+	regex  *regexp.Regexp
+	ipnets []*net.IPNet
+}
+
+// ParseEndpointRouteRule 解析规则表达式
+func ParseEndpointRouteRule(expr string) (*EndpointRouteRule, error) {
+	idx := strings.LastIndex(expr, "->")
+	if idx < 0 {
+		return nil, fmt.Errorf("illegal route rule, version not found, rule: %s", expr)
+	}
+	version := strings.TrimSpace(expr[idx+2:])
+	if "" == version {
+		return nil, fmt.Errorf("illegal route rule, version is empty, rule: %s", expr)
+	}
+	rule := &EndpointRouteRule{Expr: expr, Version: version}
+	for _, cond := range strings.Split(expr[:idx], "&&") {
+		fields := strings.Fields(cond)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("illegal route rule condition: %s, rule: %s", cond, expr)
+		}
+		scope, key, ok := pkg.LookupParseExpr(fields[0])
+		if !ok {
+			return nil, fmt.Errorf("illegal route rule lookup: %s, rule: %s", fields[0], expr)
+		}
+		c := ruleCondition{scope: scope, key: key, op: strings.ToLower(fields[1]), value: fields[2]}
+		switch c.op {
+		case ruleOpEqual, ruleOpNotEqual:
+			// nop
+		case ruleOpMatch:
+			regex, err := regexp.Compile(c.value)
+			if nil != err {
+				return nil, fmt.Errorf("illegal route rule regexp: %s, rule: %s, err: %w", c.value, expr, err)
+			}
+			c.regex = regex
+		case ruleOpIn:
+			for _, cidr := range strings.Split(c.value, ",") {
+				_, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+				if nil != err {
+					return nil, fmt.Errorf("illegal route rule cidr: %s, rule: %s, err: %w", cidr, expr, err)
+				}
+				c.ipnets = append(c.ipnets, ipnet)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported route rule op: %s, rule: %s", fields[1], expr)
+		}
+		rule.conditions = append(rule.conditions, c)
+	}
+	return rule, nil
+}
+
+// Match 判断请求是否匹配规则的全部条件
+func (r *EndpointRouteRule) Match(webc flux.WebContext) bool {
+	for _, c := range r.conditions {
+		if !c.match(webserver.LookupValue(c.scope, c.key, webc)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c ruleCondition) match(value string) bool {
+	switch c.op {
+	case ruleOpEqual:
+		return value == c.value
+	case ruleOpNotEqual:
+		return value != c.value
+	case ruleOpMatch:
+		return c.regex.MatchString(value)
+	case ruleOpIn:
+		if host, _, err := net.SplitHostPort(value); nil == err {
+			value = host
+		}
+		ip := net.ParseIP(strings.TrimSpace(value))
+		if nil == ip {
+			return false
+		}
+		for _, ipnet := range c.ipnets {
+			if ipnet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// ParseEndpointRouteRules 解析规则列表；无效规则将被忽略，并返回其错误信息
+func ParseEndpointRouteRules(exprs []string) ([]*EndpointRouteRule, []error) {
+	rules := make([]*EndpointRouteRule, 0, len(exprs))
+	errs := make([]error, 0)
+	for _, expr := range exprs {
+		if "" == strings.TrimSpace(expr) {
+			continue
+		}
+		if rule, err := ParseEndpointRouteRule(expr); nil != err {
+			errs = append(errs, err)
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, errs
+}
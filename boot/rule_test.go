@@ -0,0 +1,26 @@
+package boot
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseEndpointRouteRule(t *testing.T) {
+	tester := assert.New(t)
+	rule, err := ParseEndpointRouteRule("header:X-Tenant == acme && request:address in 10.0.0.0/8,192.168.0.0/16 -> 2.0")
+	tester.NoError(err)
+	tester.Equal("2.0", rule.Version)
+	tester.Equal(2, len(rule.conditions))
+	tester.True(rule.conditions[0].match("acme"))
+	tester.False(rule.conditions[0].match("foo"))
+	tester.True(rule.conditions[1].match("10.1.2.3"))
+	tester.True(rule.conditions[1].match("192.168.1.1:8080"))
+	tester.False(rule.conditions[1].match("172.16.0.1"))
+	regex, err := ParseEndpointRouteRule("cookie:uid =~ ^9 -> 3.0")
+	tester.NoError(err)
+	tester.True(regex.conditions[0].match("9527"))
+	for _, illegal := range []string{"header:X-Tenant == acme", "X-Tenant == acme -> 2.0", "query:a > 1 -> 2.0", "query:a == -> 2.0"} {
+		_, err := ParseEndpointRouteRule(illegal)
+		tester.Error(err, illegal)
+	}
+}
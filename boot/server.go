@@ -6,17 +6,21 @@ import (
 	dubgo "github.com/apache/dubbo-go/config"
 	"github.com/bytepowered/flux"
 	"github.com/bytepowered/flux/admin"
+	"github.com/bytepowered/flux/backend"
 	"github.com/bytepowered/flux/context"
 	"github.com/bytepowered/flux/ext"
 	"github.com/bytepowered/flux/listen"
 	"github.com/bytepowered/flux/logger"
 	"github.com/bytepowered/flux/pkg"
+	"github.com/bytepowered/flux/webserver"
 	"github.com/spf13/cast"
+	"github.com/spf13/viper"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -46,7 +50,13 @@ type BootstrapServer struct {
 	listenServers      map[string]flux.ListenServer
 	ctxHooks           []flux.ContextHook
 	endpointSelectFunc EndpointSelectFunc
+	stickyLookupExpr   string
+	routeRules         sync.Map // routeKey -> []*EndpointRouteRule
+	routeServers       sync.Map // routeKey -> ListenServer id
+	globalRouteRules   []*EndpointRouteRule
 	router             *Router
+	inflight           *InflightTracker
+	drainDelay         time.Duration
 	ctxPool            sync.Pool
 	started            chan struct{}
 	stopped            chan struct{}
@@ -68,6 +78,14 @@ func WithEndpointSelectFunc(fun EndpointSelectFunc) Option {
 	}
 }
 
+// WithEndpointStickyLookup 配置默认的Endpoint版本粘性选择Lookup表达式，例如：header:X-User-Id；
+// Endpoint属性sticky优先于此配置。
+func WithEndpointStickyLookup(lookupExpr string) Option {
+	return func(bs *BootstrapServer) {
+		bs.stickyLookupExpr = lookupExpr
+	}
+}
+
 // WithBanner 配置服务Banner
 func WithServerBanner(banner string) Option {
 	return func(bs *BootstrapServer) {
@@ -104,8 +122,15 @@ func NewDefaultBootstrapServer(options ...Option) *BootstrapServer {
 				listen.WithWebHandlers([]listen.WebHandlerTuple{
 					{Method: "GET", Pattern: "/inspect/endpoints", Handler: admin.InspectEndpointsHandler},
 					{Method: "GET", Pattern: "/inspect/services", Handler: admin.InspectServicesHandler},
+					{Method: "GET", Pattern: "/inspect/states", Handler: admin.InspectStatesHandler},
 				}),
 			)),
+		// 就绪检查
+		func(bs *BootstrapServer) {
+			if server, ok := bs.GetListenServer(ListenServerIdAdmin); ok {
+				server.AddHandler("GET", "/health/readiness", bs.inflight.ReadinessHandler)
+			}
+		},
 	}
 	return NewBootstrapServerWith(context.DefaultContextFactory, append(opts, options...)...)
 }
@@ -113,6 +138,7 @@ func NewDefaultBootstrapServer(options ...Option) *BootstrapServer {
 func NewBootstrapServerWith(factory func() flux.Context, opts ...Option) *BootstrapServer {
 	srv := &BootstrapServer{
 		router:        NewRouter(),
+		inflight:      NewInflightTracker(),
 		listenServers: make(map[string]flux.ListenServer, 2),
 		ctxPool:       sync.Pool{New: func() interface{} { return factory() }},
 		ctxHooks:      make([]flux.ContextHook, 0, 4),
@@ -133,6 +159,17 @@ func (s *BootstrapServer) Prepare() error {
 
 // Initial
 func (s *BootstrapServer) Initial() error {
+	// Global route rules
+	rules, errs := ParseEndpointRouteRules(viper.GetStringSlice(ConfigKeyEndpointRouteRules))
+	if len(errs) > 0 {
+		return fmt.Errorf("illegal global route rules: %v", errs)
+	}
+	s.globalRouteRules = rules
+	s.drainDelay = viper.GetDuration(ConfigKeyShutdownDrainDelay)
+	// Response envelopes
+	if err := backend.InitResponseEnvelopes(flux.NewConfigurationOfNS(backend.ConfigKeyResponseEnvelopes)); nil != err {
+		return err
+	}
 	// Listen Server
 	for id, srv := range s.listenServers {
 		if err := srv.Init(LoadListenServerConfig(id)); nil != err {
@@ -213,9 +250,8 @@ func (s *BootstrapServer) startDiscovery(endpoints chan flux.HttpEndpointEvent,
 	return nil
 }
 
-func (s *BootstrapServer) route(webc flux.WebContext, server flux.ListenServer, endpoints *flux.MultiEndpoint) error {
-	version := s.endpointSelectFunc(webc)
-	endpoint, found := endpoints.LookupByVersion(version)
+func (s *BootstrapServer) route(webc flux.WebContext, server flux.ListenServer, routeKey string, endpoints *flux.MultiEndpoint) error {
+	endpoint, found := s.selectEndpoint(webc, routeKey, endpoints)
 	requestId := cast.ToString(webc.Variable(flux.HeaderXRequestId))
 	defer func() {
 		if r := recover(); r != nil {
@@ -236,6 +272,8 @@ func (s *BootstrapServer) route(webc flux.WebContext, server flux.ListenServer,
 		}
 		return flux.ErrRouteNotFound
 	}
+	inflight := s.inflight.Enter(endpointServerId(endpoint), requestId)
+	defer s.inflight.Leave(inflight)
 	ctxw := s.acquireContext(requestId, webc, endpoint)
 	defer s.releaseContext(ctxw)
 	// route call
@@ -273,6 +311,7 @@ func (s *BootstrapServer) onBackendServiceEvent(event flux.BackendServiceEvent)
 		if "" != service.AliasId {
 			ext.SetBackendServiceById(service.AliasId, service)
 		}
+		prewarmBackendService(service)
 	case flux.EventTypeUpdated:
 		logger.Infow("SERVER:META:SERVICE:UPDATE",
 			"service-id", service.ServiceId, "alias-id", service.AliasId)
@@ -280,6 +319,7 @@ func (s *BootstrapServer) onBackendServiceEvent(event flux.BackendServiceEvent)
 		if "" != service.AliasId {
 			ext.SetBackendServiceById(service.AliasId, service)
 		}
+		prewarmBackendService(service)
 	case flux.EventTypeRemoved:
 		logger.Infow("SERVER:META:SERVICE:REMOVE",
 			"service-id", service.ServiceId, "alias-id", service.AliasId)
@@ -301,6 +341,11 @@ func (s *BootstrapServer) onHttpEndpointEvent(event flux.HttpEndpointEvent) {
 	routeKey := fmt.Sprintf("%s#%s", method, pattern)
 	// Refresh endpoint
 	endpoint := event.Endpoint
+	// 删除事件不注册新的路由
+	if flux.EventTypeRemoved == event.EventType {
+		s.onHttpEndpointRemoved(routeKey, method, pattern, &endpoint)
+		return
+	}
 	initArguments(endpoint.Service.Arguments)
 	initArguments(endpoint.Permission.Arguments)
 	bind, isreg := s.selectMultiEndpoint(routeKey, &endpoint)
@@ -308,16 +353,16 @@ func (s *BootstrapServer) onHttpEndpointEvent(event flux.HttpEndpointEvent) {
 	case flux.EventTypeAdded:
 		logger.Infow("SERVER:META:ENDPOINT:ADD", "version", endpoint.Version, "method", method, "pattern", pattern)
 		bind.Update(endpoint.Version, &endpoint)
+		prewarmBackendService(endpoint.Service)
+		s.refreshRouteRules(routeKey, bind)
 		// 根据Endpoint属性，选择ListenServer来绑定
 		if isreg {
-			id := endpoint.GetAttr(flux.EndpointAttrTagServerId).GetString()
-			if id == "" {
-				id = ListenServerIdDefault
-			}
+			id := endpointServerId(&endpoint)
 			server, ok := s.GetListenServer(id)
 			if ok {
 				logger.Infow("SERVER:META:ENDPOINT:HTTP_HANDLER/"+id, "method", method, "pattern", pattern)
-				server.AddHandler(method, pattern, s.newEndpointHandler(server, bind))
+				server.AddHandler(method, pattern, s.newEndpointHandler(server, routeKey, bind))
+				s.routeServers.Store(routeKey, id)
 			} else {
 				logger.Errorw("SERVER:META:ENDPOINT:LISTENER_MISSED/"+id, "method", method, "pattern", pattern)
 			}
@@ -325,9 +370,46 @@ func (s *BootstrapServer) onHttpEndpointEvent(event flux.HttpEndpointEvent) {
 	case flux.EventTypeUpdated:
 		logger.Infow("SERVER:META:ENDPOINT:UPDATE", "version", endpoint.Version, "method", method, "pattern", pattern)
 		bind.Update(endpoint.Version, &endpoint)
-	case flux.EventTypeRemoved:
-		logger.Infow("SERVER:META:ENDPOINT:REMOVE", "method", method, "pattern", pattern)
-		bind.Delete(endpoint.Version)
+		prewarmBackendService(endpoint.Service)
+		s.refreshRouteRules(routeKey, bind)
+	}
+}
+
+// onHttpEndpointRemoved 删除Endpoint版本；最后一个版本被删除时，注销其路由
+func (s *BootstrapServer) onHttpEndpointRemoved(routeKey, method, pattern string, endpoint *flux.Endpoint) {
+	logger.Infow("SERVER:META:ENDPOINT:REMOVE", "version", endpoint.Version, "method", method, "pattern", pattern)
+	bind, ok := ext.SelectEndpoint(routeKey)
+	if !ok {
+		return
+	}
+	bind.Delete(endpoint.Version)
+	if !bind.IsEmpty() {
+		s.refreshRouteRules(routeKey, bind)
+		return
+	}
+	ext.RemoveEndpoint(routeKey)
+	s.routeRules.Delete(routeKey)
+	id, ok := s.routeServers.Load(routeKey)
+	if !ok {
+		return
+	}
+	s.routeServers.Delete(routeKey)
+	if server, ok := s.GetListenServer(id.(string)); ok {
+		logger.Infow("SERVER:META:ENDPOINT:HTTP_HANDLER_REMOVE/"+id.(string), "method", method, "pattern", pattern)
+		server.RemoveHandler(method, pattern)
+	}
+}
+
+// prewarmBackendService 发现BackendService时，由支持预热的BackendTransport预先初始化服务的调用资源
+func prewarmBackendService(service flux.BackendService) {
+	proto := service.AttrRpcProto()
+	if "" == proto {
+		return
+	}
+	if transport, ok := ext.GetBackendTransport(proto); ok {
+		if prewarmer, ok := transport.(flux.BackendTransportPrewarmer); ok {
+			prewarmer.Prewarm(service)
+		}
 	}
 }
 
@@ -335,6 +417,8 @@ func (s *BootstrapServer) onHttpEndpointEvent(event flux.HttpEndpointEvent) {
 func (s *BootstrapServer) Shutdown(ctx goctx.Context) error {
 	logger.Info("Server shutdown...")
 	defer close(s.stopped)
+	// 等待正在执行的请求完成后，再关闭服务和后端连接
+	s.inflight.drain(ctx, s.drainDelay)
 	for id, server := range s.listenServers {
 		if err := server.Close(ctx); nil != err {
 			logger.Warnw("Server["+id+"] shutdown http server", "error", err)
@@ -402,12 +486,76 @@ func (s *BootstrapServer) AddContextHook(f flux.ContextHook) {
 	s.ctxHooks = append(s.ctxHooks, f)
 }
 
-func (s *BootstrapServer) newEndpointHandler(server flux.ListenServer, endpoint *flux.MultiEndpoint) flux.WebHandler {
+func (s *BootstrapServer) newEndpointHandler(server flux.ListenServer, routeKey string, endpoint *flux.MultiEndpoint) flux.WebHandler {
 	return func(webc flux.WebContext) error {
-		return s.route(webc, server, endpoint)
+		return s.route(webc, server, routeKey, endpoint)
 	}
 }
 
+// selectEndpoint 选择Endpoint版本；优先级：请求指定版本 > Endpoint规则 > 全局规则 > 版本权重
+func (s *BootstrapServer) selectEndpoint(webc flux.WebContext, routeKey string, endpoints *flux.MultiEndpoint) (*flux.Endpoint, bool) {
+	if version := s.endpointSelectFunc(webc); "" != version {
+		return endpoints.LookupByVersion(version)
+	}
+	if rules, ok := s.routeRules.Load(routeKey); ok {
+		if ep, ok := lookupByRouteRules(webc, endpoints, rules.([]*EndpointRouteRule)); ok {
+			return ep, true
+		}
+	}
+	if ep, ok := lookupByRouteRules(webc, endpoints, s.globalRouteRules); ok {
+		return ep, true
+	}
+	return endpoints.LookupByWeight(s.lookupStickyKey(webc, endpoints))
+}
+
+// refreshRouteRules 根据Endpoint各版本声明的规则属性，重新编译路由规则
+func (s *BootstrapServer) refreshRouteRules(routeKey string, endpoints *flux.MultiEndpoint) {
+	versions := endpoints.ToSerializable()
+	keys := make([]string, 0, len(versions))
+	for v := range versions {
+		keys = append(keys, v)
+	}
+	sort.Strings(keys)
+	exprs := make([]string, 0)
+	for _, v := range keys {
+		for _, attr := range versions[v].GetAttrs(flux.EndpointAttrTagRouteRule) {
+			exprs = append(exprs, attr.GetString())
+		}
+	}
+	rules, errs := ParseEndpointRouteRules(exprs)
+	for _, err := range errs {
+		logger.Warnw("SERVER:META:ENDPOINT:ILLEGAL_ROUTE_RULE", "route", routeKey, "error", err)
+	}
+	if len(rules) > 0 {
+		s.routeRules.Store(routeKey, rules)
+	} else {
+		s.routeRules.Delete(routeKey)
+	}
+}
+
+func lookupByRouteRules(webc flux.WebContext, endpoints *flux.MultiEndpoint, rules []*EndpointRouteRule) (*flux.Endpoint, bool) {
+	for _, rule := range rules {
+		if !rule.Match(webc) {
+			continue
+		}
+		if ep, ok := endpoints.LookupByVersion(rule.Version); ok && ep.Version == rule.Version {
+			return ep, true
+		}
+	}
+	return nil, false
+}
+
+func (s *BootstrapServer) lookupStickyKey(webc flux.WebContext, endpoints *flux.MultiEndpoint) string {
+	expr := endpoints.AttrSticky()
+	if "" == expr {
+		expr = s.stickyLookupExpr
+	}
+	if "" == expr {
+		return ""
+	}
+	return webserver.LookupValueByExpr(expr, webc)
+}
+
 func (s *BootstrapServer) selectMultiEndpoint(routeKey string, endpoint *flux.Endpoint) (*flux.MultiEndpoint, bool) {
 	if mve, ok := ext.SelectEndpoint(routeKey); ok {
 		return mve, false
@@ -423,6 +571,8 @@ func (s *BootstrapServer) acquireContext(id string, webc flux.WebContext, endpoi
 }
 
 func (s *BootstrapServer) releaseContext(context *context.DefaultContext) {
+	// 响应已写入，等待流量镜像完成请求数据的复制
+	AwaitMirrorSnapshot(context)
 	context.Release()
 	s.ctxPool.Put(context)
 }
@@ -444,6 +594,13 @@ func (s *BootstrapServer) defaultListenServer() flux.ListenServer {
 	return nil
 }
 
+func endpointServerId(endpoint *flux.Endpoint) string {
+	if id := endpoint.GetAttr(flux.EndpointAttrTagServerId).GetString(); "" != id {
+		return id
+	}
+	return ListenServerIdDefault
+}
+
 func LoadListenServerConfig(id string) *flux.Configuration {
 	return flux.NewConfigurationOfNS(flux.NamespaceListenServer + "." + id)
 }
@@ -158,6 +158,9 @@ type Context interface {
 	// Context 返回Http请求的Context对象。用于判定Http请求是否被Cancel。
 	Context() context.Context
 
+	// SetContext 替换请求的Context对象；用于设置请求的截止时间。
+	SetContext(ctx context.Context)
+
 	// StartAt 返回Http请求起始的服务器时间
 	StartAt() time.Time
 
@@ -22,6 +22,7 @@ type DefaultContext struct {
 	request    *DefaultRequest
 	response   *DefaultResponse
 	ctxLogger  flux.Logger
+	goctx      context.Context
 }
 
 func DefaultContextFactory() flux.Context {
@@ -128,9 +129,16 @@ func (c *DefaultContext) GetVariable(key string) (interface{}, bool) {
 }
 
 func (c *DefaultContext) Context() context.Context {
+	if nil != c.goctx {
+		return c.goctx
+	}
 	return c.webc.Context()
 }
 
+func (c *DefaultContext) SetContext(ctx context.Context) {
+	c.goctx = ctx
+}
+
 func (c *DefaultContext) Metrics() []flux.Metric {
 	dist := make([]flux.Metric, len(c.metrics))
 	copy(dist, c.metrics)
@@ -181,4 +189,5 @@ func (c *DefaultContext) Release() {
 	c.request.reset()
 	c.response.reset()
 	c.ctxLogger = nil
+	c.goctx = nil
 }
@@ -22,6 +22,9 @@ type MockRequest struct {
 }
 
 func (r *MockRequest) Context() context.Context {
+	if c, ok := r.values["context"].(context.Context); ok {
+		return c
+	}
 	return context.TODO()
 }
 
@@ -150,6 +153,7 @@ type MockContext struct {
 	time      time.Time
 	request   *MockRequest
 	ctxLogger flux.Logger
+	goctx     context.Context
 }
 
 func (mc *MockContext) StartAt() time.Time {
@@ -185,6 +189,9 @@ func (mc *MockContext) Response() flux.Response {
 }
 
 func (mc *MockContext) Endpoint() flux.Endpoint {
+	if ep, ok := mc.request.values["endpoint"]; ok {
+		return ep.(flux.Endpoint)
+	}
 	return flux.Endpoint{}
 }
 
@@ -244,9 +251,16 @@ func (mc *MockContext) SetVariable(name string, value interface{}) {
 }
 
 func (mc *MockContext) Context() context.Context {
+	if nil != mc.goctx {
+		return mc.goctx
+	}
 	return context.Background()
 }
 
+func (mc *MockContext) SetContext(ctx context.Context) {
+	mc.goctx = ctx
+}
+
 func (mc *MockContext) SetLogger(logger flux.Logger) {
 	mc.ctxLogger = logger
 }
@@ -0,0 +1,304 @@
+package context
+
+import (
+	"bytes"
+	"context"
+	"github.com/bytepowered/flux"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var (
+	_ flux.Request = new(SnapshotRequest)
+	_ flux.Context = new(SnapshotContext)
+)
+
+// SnapshotRequest 复制请求数据的Request实现，与Web请求的生命周期无关
+type SnapshotRequest struct {
+	goctx     context.Context
+	method    string
+	host      string
+	userAgent string
+	uri       string
+	url       *url.URL
+	address   string
+	header    http.Header
+	query     url.Values
+	path      url.Values
+	form      url.Values
+	cookies   []*http.Cookie
+	body      []byte
+}
+
+// NewSnapshotRequest 复制Request的请求数据，包括Body数据
+func NewSnapshotRequest(req flux.Request, goctx context.Context) (*SnapshotRequest, error) {
+	snapshot := &SnapshotRequest{
+		goctx:     goctx,
+		method:    req.Method(),
+		host:      req.Host(),
+		userAgent: req.UserAgent(),
+		uri:       req.URI(),
+		address:   req.Address(),
+		header:    req.HeaderVars().Clone(),
+		query:     copyValues(req.QueryVars()),
+		path:      copyValues(req.PathVars()),
+		form:      copyValues(req.FormVars()),
+		cookies:   req.CookieVars(),
+	}
+	if u := req.URL(); nil != u {
+		cu := *u
+		snapshot.url = &cu
+	}
+	reader, err := req.BodyReader()
+	if nil != err {
+		return nil, err
+	}
+	if nil != reader {
+		data, err := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if nil != err {
+			return nil, err
+		}
+		snapshot.body = data
+	}
+	return snapshot, nil
+}
+
+func (r *SnapshotRequest) Context() context.Context {
+	return r.goctx
+}
+
+func (r *SnapshotRequest) Method() string {
+	return r.method
+}
+
+func (r *SnapshotRequest) Host() string {
+	return r.host
+}
+
+func (r *SnapshotRequest) UserAgent() string {
+	return r.userAgent
+}
+
+func (r *SnapshotRequest) URI() string {
+	return r.uri
+}
+
+func (r *SnapshotRequest) URL() *url.URL {
+	return r.url
+}
+
+func (r *SnapshotRequest) Address() string {
+	return r.address
+}
+
+func (r *SnapshotRequest) OnHeaderVars(access func(header http.Header)) {
+	access(r.header)
+}
+
+func (r *SnapshotRequest) HeaderVars() http.Header {
+	return r.header
+}
+
+func (r *SnapshotRequest) QueryVars() url.Values {
+	return r.query
+}
+
+func (r *SnapshotRequest) PathVars() url.Values {
+	return r.path
+}
+
+func (r *SnapshotRequest) FormVars() url.Values {
+	return r.form
+}
+
+func (r *SnapshotRequest) CookieVars() []*http.Cookie {
+	return r.cookies
+}
+
+func (r *SnapshotRequest) HeaderVar(name string) string {
+	return r.header.Get(name)
+}
+
+func (r *SnapshotRequest) QueryVar(name string) string {
+	return r.query.Get(name)
+}
+
+func (r *SnapshotRequest) PathVar(name string) string {
+	return r.path.Get(name)
+}
+
+func (r *SnapshotRequest) FormVar(name string) string {
+	return r.form.Get(name)
+}
+
+func (r *SnapshotRequest) CookieVar(name string) *http.Cookie {
+	for _, c := range r.cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (r *SnapshotRequest) BodyReader() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(r.body)), nil
+}
+
+// SnapshotContext 复制请求Context数据的Context实现；
+// 用于请求结束后，仍需要异步执行的后端服务调用，例如流量镜像。
+type SnapshotContext struct {
+	requestId  string
+	endpoint   flux.Endpoint
+	request    *SnapshotRequest
+	response   *DefaultResponse
+	attributes *sync.Map
+	variables  *sync.Map
+	metrics    []flux.Metric
+	startTime  time.Time
+	ctxLogger  flux.Logger
+	mutex      sync.Mutex
+}
+
+// NewSnapshotContext 复制Context的请求数据和Attributes，生成与Web请求生命周期无关的Context。
+// 注意：只能在原Context被释放前调用。
+func NewSnapshotContext(ctx flux.Context, goctx context.Context) (*SnapshotContext, error) {
+	request, err := NewSnapshotRequest(ctx.Request(), goctx)
+	if nil != err {
+		return nil, err
+	}
+	snapshot := &SnapshotContext{
+		requestId:  ctx.RequestId(),
+		endpoint:   ctx.Endpoint(),
+		request:    request,
+		response:   NewDefaultResponse(),
+		attributes: new(sync.Map),
+		variables:  new(sync.Map),
+		metrics:    make([]flux.Metric, 0, 4),
+		startTime:  time.Now(),
+		ctxLogger:  ctx.Logger(),
+	}
+	for k, v := range ctx.Attributes() {
+		snapshot.attributes.Store(k, v)
+	}
+	return snapshot, nil
+}
+
+func (c *SnapshotContext) Method() string {
+	return c.request.Method()
+}
+
+func (c *SnapshotContext) URI() string {
+	return c.request.URI()
+}
+
+func (c *SnapshotContext) RequestId() string {
+	return c.requestId
+}
+
+func (c *SnapshotContext) Request() flux.Request {
+	return c.request
+}
+
+func (c *SnapshotContext) Response() flux.Response {
+	return c.response
+}
+
+func (c *SnapshotContext) Endpoint() flux.Endpoint {
+	return c.endpoint
+}
+
+func (c *SnapshotContext) BackendService() flux.BackendService {
+	return c.endpoint.Service
+}
+
+func (c *SnapshotContext) BackendServiceId() string {
+	return c.endpoint.Service.ServiceID()
+}
+
+func (c *SnapshotContext) Attributes() map[string]interface{} {
+	out := make(map[string]interface{}, 16)
+	c.attributes.Range(func(k, v interface{}) bool {
+		out[k.(string)] = v
+		return true
+	})
+	return out
+}
+
+func (c *SnapshotContext) Attribute(key string, defval interface{}) interface{} {
+	if v, ok := c.GetAttribute(key); ok {
+		return v
+	}
+	return defval
+}
+
+func (c *SnapshotContext) GetAttribute(key string) (interface{}, bool) {
+	return c.attributes.Load(key)
+}
+
+func (c *SnapshotContext) SetAttribute(key string, value interface{}) {
+	c.attributes.Store(key, value)
+}
+
+func (c *SnapshotContext) Variable(key string, defval interface{}) interface{} {
+	if v, ok := c.GetVariable(key); ok {
+		return v
+	}
+	return defval
+}
+
+func (c *SnapshotContext) GetVariable(key string) (interface{}, bool) {
+	return c.variables.Load(key)
+}
+
+func (c *SnapshotContext) SetVariable(key string, value interface{}) {
+	c.variables.Store(key, value)
+}
+
+func (c *SnapshotContext) Context() context.Context {
+	return c.request.goctx
+}
+
+func (c *SnapshotContext) SetContext(ctx context.Context) {
+	c.request.goctx = ctx
+}
+
+func (c *SnapshotContext) StartAt() time.Time {
+	return c.startTime
+}
+
+func (c *SnapshotContext) AddMetric(name string, elapsed time.Duration) {
+	c.mutex.Lock()
+	c.metrics = append(c.metrics, flux.Metric{
+		Name: name, Elapsed: elapsed, Elapses: elapsed.String(),
+	})
+	c.mutex.Unlock()
+}
+
+func (c *SnapshotContext) Metrics() []flux.Metric {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	dist := make([]flux.Metric, len(c.metrics))
+	copy(dist, c.metrics)
+	return dist
+}
+
+func (c *SnapshotContext) SetLogger(logger flux.Logger) {
+	c.ctxLogger = logger
+}
+
+func (c *SnapshotContext) Logger() flux.Logger {
+	return c.ctxLogger
+}
+
+func copyValues(values url.Values) url.Values {
+	out := make(url.Values, len(values))
+	for k, vs := range values {
+		out[k] = append([]string(nil), vs...)
+	}
+	return out
+}
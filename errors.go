@@ -7,29 +7,71 @@ const (
 	ErrorCodeGatewayBackend   = "GATEWAY:BACKEND"
 	ErrorCodeGatewayEndpoint  = "GATEWAY:ENDPOINT"
 	ErrorCodeGatewayCircuited = "GATEWAY:CIRCUITED"
+	ErrorCodeGatewayTimeout   = "GATEWAY:TIMEOUT"
 	ErrorCodeRequestInvalid   = "REQUEST:INVALID"
 	ErrorCodeRequestNotFound  = "REQUEST:NOT_FOUND"
 	ErrorCodePermissionDenied = "PERMISSION:ACCESS_DENIED"
+	ErrorCodeJwtInvalid       = "JWT:INVALID"
 )
 
 const (
 	ErrorMessageBackendDecodeResponse = "BACKEND:DECODE_RESPONSE"
+	ErrorMessageEnvelopeBusinessError = "BACKEND:ENVELOPE:BUSINESS_ERROR"
 
 	ErrorMessageDubboInvokeFailed        = "BACKEND:DU:INVOKE"
 	ErrorMessageDubboAssembleFailed      = "BACKEND:DU:ASSEMBLE"
 	ErrorMessageDubboDecodeInvalidHeader = "BACKEND:DU:DECODE:INVALID_HEADERS"
 	ErrorMessageDubboDecodeInvalidStatus = "BACKEND:DU:DECODE:INVALID_STATUS"
 
-	ErrorMessageHttpInvokeFailed   = "BACKEND:HT:INVOKE"
-	ErrorMessageHttpAssembleFailed = "BACKEND:HT:ASSEMBLE"
+	ErrorMessageHttpInvokeFailed      = "BACKEND:HT:INVOKE"
+	ErrorMessageHttpAssembleFailed    = "BACKEND:HT:ASSEMBLE"
+	ErrorMessageHttpNoAvailableTarget = "BACKEND:HT:NO_AVAILABLE_TARGET"
+
+	ErrorMessageGrpcInvokeFailed   = "BACKEND:GR:INVOKE"
+	ErrorMessageGrpcAssembleFailed = "BACKEND:GR:ASSEMBLE"
+	ErrorMessageGrpcMethodNotFound = "BACKEND:GR:METHOD_NOT_FOUND"
+
+	ErrorMessageJsonRpcInvokeFailed   = "BACKEND:JR:INVOKE"
+	ErrorMessageJsonRpcAssembleFailed = "BACKEND:JR:ASSEMBLE"
+	ErrorMessageJsonRpcDecodeFailed   = "BACKEND:JR:DECODE"
+
+	ErrorMessageFastCGIInvokeFailed   = "BACKEND:FC:INVOKE"
+	ErrorMessageFastCGIAssembleFailed = "BACKEND:FC:ASSEMBLE"
+
+	ErrorMessageAggregateInvokeFailed    = "BACKEND:AG:INVOKE"
+	ErrorMessageAggregateAssembleFailed  = "BACKEND:AG:ASSEMBLE"
+	ErrorMessageAggregateServiceNotFound = "BACKEND:AG:SERVICE_NOT_FOUND"
+
+	ErrorMessagePipelineStepFailed      = "BACKEND:PL:STEP_FAILED"
+	ErrorMessagePipelineAssembleFailed  = "BACKEND:PL:ASSEMBLE"
+	ErrorMessagePipelineServiceNotFound = "BACKEND:PL:SERVICE_NOT_FOUND"
+
+	ErrorMessageMockIllegalExtensions = "BACKEND:MK:ILLEGAL_EXTENSIONS"
+	ErrorMessageMockInvokeCanceled    = "BACKEND:MK:CANCELED"
+
+	ErrorMessageWebSocketNotUpgrade         = "BACKEND:WS:NOT_UPGRADE"
+	ErrorMessageWebSocketHandshakeFailed    = "BACKEND:WS:HANDSHAKE"
+	ErrorMessageWebSocketTooManyConnections = "BACKEND:WS:TOO_MANY_CONNECTIONS"
+
+	ErrorMessageStaticFileNotFound       = "BACKEND:ST:FILE_NOT_FOUND"
+	ErrorMessageStaticFileSystemNotFound = "BACKEND:ST:FS_NOT_FOUND"
+	ErrorMessageStaticMethodNotAllowed   = "BACKEND:ST:METHOD_NOT_ALLOWED"
 
 	ErrorMessagePermissionAccessDenied    = "PERMISSION:ACCESS_DENIED"
 	ErrorMessagePermissionServiceNotFound = "PERMISSION:SERVICE:NOT_FOUND"
 	ErrorMessagePermissionVerifyError     = "PERMISSION:VERIFY:ERROR"
 
+	ErrorMessageJwtMissingToken = "JWT:MISSING_TOKEN"
+	ErrorMessageJwtMalformed    = "JWT:MALFORMED"
+	ErrorMessageJwtExpired      = "JWT:EXPIRED"
+	ErrorMessageJwtVerifyFailed = "JWT:VERIFY_FAILED"
+	ErrorMessageJwtSecretLoad   = "JWT:SECRET:LOAD"
+
 	ErrorMessageWebServerRequestNotFound = "SERVER:REQUEST:NOT_FOUND"
 
 	ErrorMessageRequestPrepare = "REQUEST:BODY:PREPARE"
+
+	ErrorMessageRouteDeadlineExceeded = "ROUTE:DEADLINE_EXCEEDED"
 )
 
 var (
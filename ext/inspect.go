@@ -0,0 +1,36 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/pkg"
+	"sort"
+	"sync"
+)
+
+var (
+	inspectFuncs = new(sync.Map)
+)
+
+// SetInspectFunc 注册组件内部状态的查询函数，用于Admin服务查询
+func SetInspectFunc(name string, f func() interface{}) {
+	inspectFuncs.Store(name, pkg.RequireNotNil(f, "InspectFunc is nil").(func() interface{}))
+}
+
+// GetInspectFunc 返回指定名称的状态查询函数
+func GetInspectFunc(name string) (func() interface{}, bool) {
+	f, ok := inspectFuncs.Load(name)
+	if ok {
+		return f.(func() interface{}), true
+	}
+	return nil, false
+}
+
+// GetInspectNames 返回已注册的状态查询名称列表
+func GetInspectNames() []string {
+	names := make([]string, 0, 4)
+	inspectFuncs.Range(func(k, _ interface{}) bool {
+		names = append(names, k.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
@@ -23,6 +23,10 @@ func RegisterEndpoint(key string, endpoint *flux.Endpoint) *flux.MultiEndpoint {
 	return mve
 }
 
+func RemoveEndpoint(key string) {
+	endpoints.Delete(key)
+}
+
 func LoadEndpoints() map[string]*flux.MultiEndpoint {
 	out := make(map[string]*flux.MultiEndpoint, 32)
 	endpoints.Range(func(key, value interface{}) bool {
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+// ExpiringCache 简单的带过期时间的本地缓存；超出容量时，优先淘汰已过期数据，其次随机淘汰。
+type ExpiringCache struct {
+	size       int
+	expiration time.Duration
+	entries    map[string]cacheEntry
+	mutex      sync.RWMutex
+}
+
+func NewExpiringCache(size int, expiration time.Duration) *ExpiringCache {
+	return &ExpiringCache{
+		size:       size,
+		expiration: expiration,
+		entries:    make(map[string]cacheEntry, size),
+	}
+}
+
+// Get 返回指定Key的未过期缓存值
+func (c *ExpiringCache) Get(key string) (interface{}, bool) {
+	c.mutex.RLock()
+	entry, ok := c.entries[key]
+	c.mutex.RUnlock()
+	if !ok || time.Now().After(entry.expireAt) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set 设置缓存键值
+func (c *ExpiringCache) Set(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		c.evict()
+	}
+	c.entries[key] = cacheEntry{value: value, expireAt: time.Now().Add(c.expiration)}
+}
+
+// Remove 删除缓存键值
+func (c *ExpiringCache) Remove(key string) {
+	c.mutex.Lock()
+	delete(c.entries, key)
+	c.mutex.Unlock()
+}
+
+func (c *ExpiringCache) evict() {
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expireAt) {
+			delete(c.entries, k)
+		}
+	}
+	// Map遍历顺序随机
+	for k := range c.entries {
+		if len(c.entries) < c.size {
+			return
+		}
+		delete(c.entries, k)
+	}
+}
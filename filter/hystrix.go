@@ -24,6 +24,11 @@ const (
 	TypeIdHystrixFilter = "hystrix_filter"
 )
 
+const (
+	// hystrixNoTimeout 没有配置熔断超时时间时，使用的熔断超时时间（毫秒）；由请求剩余的截止时间约束执行时间
+	hystrixNoTimeout = int(24 * time.Hour / time.Millisecond)
+)
+
 func NewHystrixFilter(c HystrixConfig) *HystrixFilter {
 	return &HystrixFilter{
 		Config: c,
@@ -63,9 +68,13 @@ func (r *HystrixFilter) Init(config *flux.Configuration) error {
 		HystrixConfigKeyErrorPercentThreshold:  50,
 		HystrixConfigKeySleepWindow:            500,
 		HystrixConfigKeyMaxRequest:             10,
-		HystrixConfigKeyTimeout:                1000,
+		HystrixConfigKeyTimeout:                0,
 	})
+	// 熔断超时时间：默认为0，跟随请求剩余的截止时间；配置时，只能缩短请求剩余的截止时间
 	r.Config.timeout = int(config.GetInt64(HystrixConfigKeyTimeout))
+	if r.Config.timeout <= 0 {
+		r.Config.timeout = hystrixNoTimeout
+	}
 	r.Config.maxConcurrentRequests = int(config.GetInt64(HystrixConfigKeyMaxRequest))
 	r.Config.requestVolumeThreshold = int(config.GetInt64(HystrixConfigKeyRequestVolumeThreshold))
 	r.Config.sleepWindow = int(config.GetInt64(HystrixConfigKeySleepWindow))
@@ -107,11 +116,19 @@ func (r *HystrixFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
 		}
 		var reterr *flux.ServeError
 		fallback := func(_ context.Context, err error) error {
-			// 返回两种类型Error：
+			// 返回三种类型Error：
 			// 1. 执行 next() 返回 *ServeError；
-			// 2. 熔断返回 hystrix.CircuitError;
+			// 2. 请求截止时间到达，或者熔断超时；
+			// 3. 熔断返回 hystrix.CircuitError;
 			if serr, ok := err.(*flux.ServeError); ok {
 				reterr = serr
+			} else if err == context.DeadlineExceeded || err == hystrix.ErrTimeout {
+				reterr = &flux.ServeError{
+					StatusCode: flux.StatusTimeout,
+					ErrorCode:  flux.ErrorCodeGatewayTimeout,
+					Message:    "CIRCUIT:TIMEOUT",
+					Internal:   err,
+				}
 			} else if cerr, ok := err.(hystrix.CircuitError); ok {
 				logger.Infow("HYSTRIX:CIRCUITED/DOWNGRADE",
 					"is-circuited", ok, "service-name", serviceName, "circuit-error", cerr)
@@ -0,0 +1,381 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/backend"
+	"github.com/bytepowered/flux/context"
+	"github.com/bytepowered/flux/ext"
+	"github.com/bytepowered/flux/logger"
+	"github.com/bytepowered/flux/pkg"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/spf13/cast"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TypeIdJwtVerificationFilter = "JwtVerificationFilter"
+)
+
+const (
+	JwtConfigKeyLookupToken     = "jwt-lookup-token"
+	JwtConfigKeyIssuerKey       = "jwt-issuer-key"
+	JwtConfigKeySubjectKey      = "jwt-subject-key"
+	JwtConfigKeyUpstreamProto   = "upstream-protocol"
+	JwtConfigKeyUpstreamHost    = "upstream-host"
+	JwtConfigKeyUpstreamUri     = "upstream-uri"
+	JwtConfigKeyUpstreamMethod  = "upstream-method"
+	JwtConfigKeyUpstreamTimeout = "upstream-timeout"
+	JwtConfigKeyValidMethods    = "jwt-valid-methods"
+)
+
+const (
+	// JwtClaimsKey Token验证通过后，以此Key将全部Claims设置到Context.Variable中
+	JwtClaimsKey = "jwt-claims"
+)
+
+var (
+	ErrJwtIssuerNotFound  = errors.New("jwt: issuer not found in claims")
+	ErrJwtSubjectNotFound = errors.New("jwt: subject not found in claims")
+	ErrJwtMethodMismatch  = errors.New("jwt: signing method does not match the secret")
+)
+
+type (
+	// JwtSecretLoadFunc 根据Token的Issuer和Subject加载签名密钥
+	JwtSecretLoadFunc func(ctx flux.Context, issuer, subject string, claims jwt.MapClaims) (secret string, err error)
+)
+
+// JwtConfig JWT验证过滤器配置
+type JwtConfig struct {
+	SkipFunc       flux.FilterSkipper
+	SecretLoadFunc JwtSecretLoadFunc
+	lookupToken    string
+	issuerKey      string
+	subjectKey     string
+	validMethods   []string
+	upstream       flux.BackendService
+	cacheDisabled  bool
+}
+
+func NewJwtVerificationFilter(c JwtConfig) *JwtVerificationFilter {
+	return &JwtVerificationFilter{
+		Configs: c,
+	}
+}
+
+// JwtVerificationFilterFactory 用于动态加载JwtVerificationFilter的工厂函数
+func JwtVerificationFilterFactory() interface{} {
+	return NewJwtVerificationFilter(JwtConfig{})
+}
+
+// JwtVerificationFilter 提供基于JWT的请求Token验证；
+// Token签名密钥根据Issuer/Subject从DUBBO或者HTTP后端服务加载，支持HS/RS/ES签名算法。
+type JwtVerificationFilter struct {
+	Disabled bool
+	Configs  JwtConfig
+	keys     *ExpiringCache
+}
+
+func (j *JwtVerificationFilter) Init(config *flux.Configuration) error {
+	logger.Info("Jwt verification filter initializing")
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyDisabled:           false,
+		ConfigKeyCacheDisabled:      false,
+		ConfigKeyCacheExpiration:    "60m",
+		ConfigKeyCacheSize:          1000,
+		JwtConfigKeyLookupToken:     "header:" + flux.HeaderAuthorization,
+		JwtConfigKeyIssuerKey:       "iss",
+		JwtConfigKeySubjectKey:      "sub",
+		JwtConfigKeyUpstreamProto:   flux.ProtoDubbo,
+		JwtConfigKeyUpstreamTimeout: "5s",
+		JwtConfigKeyValidMethods: []string{
+			"HS256", "HS384", "HS512", "RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512", "ES256", "ES384", "ES512",
+		},
+	})
+	j.Disabled = config.GetBool(ConfigKeyDisabled)
+	if j.Disabled {
+		logger.Info("Jwt verification filter was DISABLED!!")
+		return nil
+	}
+	j.Configs.lookupToken = config.GetString(JwtConfigKeyLookupToken)
+	j.Configs.issuerKey = config.GetString(JwtConfigKeyIssuerKey)
+	j.Configs.subjectKey = config.GetString(JwtConfigKeySubjectKey)
+	j.Configs.validMethods = config.GetStringSlice(JwtConfigKeyValidMethods)
+	if len(j.Configs.validMethods) == 0 {
+		return fmt.Errorf("jwt config: %s is required", JwtConfigKeyValidMethods)
+	}
+	j.Configs.cacheDisabled = config.GetBool(ConfigKeyCacheDisabled)
+	j.keys = NewExpiringCache(config.GetInt(ConfigKeyCacheSize), config.GetDuration(ConfigKeyCacheExpiration))
+	if pkg.IsNil(j.Configs.SkipFunc) {
+		j.Configs.SkipFunc = func(_ flux.Context) bool {
+			return false
+		}
+	}
+	if pkg.IsNil(j.Configs.SecretLoadFunc) {
+		upstream, err := newJwtUpstreamService(config)
+		if nil != err {
+			return err
+		}
+		j.Configs.upstream = upstream
+		j.Configs.SecretLoadFunc = j.loadUpstreamSecret
+	}
+	logger.Infow("Jwt verification config",
+		"lookup-token", j.Configs.lookupToken, "issuer-key", j.Configs.issuerKey, "subject-key", j.Configs.subjectKey,
+		"valid-methods", j.Configs.validMethods,
+		"upstream-proto", j.Configs.upstream.AttrRpcProto(), "upstream-interface", j.Configs.upstream.Interface,
+		"cache-disabled", j.Configs.cacheDisabled)
+	return nil
+}
+
+func (*JwtVerificationFilter) TypeId() string {
+	return TypeIdJwtVerificationFilter
+}
+
+func (j *JwtVerificationFilter) DoFilter(next flux.FilterHandler) flux.FilterHandler {
+	if j.Disabled {
+		return next
+	}
+	return func(ctx flux.Context) *flux.ServeError {
+		if j.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		tokenString := j.lookupToken(ctx)
+		if "" == tokenString {
+			return &flux.ServeError{
+				StatusCode: flux.StatusUnauthorized,
+				ErrorCode:  flux.ErrorCodeJwtInvalid,
+				Message:    flux.ErrorMessageJwtMissingToken,
+			}
+		}
+		claims := jwt.MapClaims{}
+		// 只接受配置的签名算法，避免Token通过alg头部指定验证算法
+		parser := &jwt.Parser{ValidMethods: j.Configs.validMethods}
+		start := time.Now()
+		token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			return j.loadVerifyKey(ctx, token)
+		})
+		ctx.AddMetric("M-"+j.TypeId(), time.Since(start))
+		if nil != err {
+			return j.newVerifyError(err)
+		}
+		if !token.Valid {
+			return &flux.ServeError{
+				StatusCode: flux.StatusUnauthorized,
+				ErrorCode:  flux.ErrorCodeJwtInvalid,
+				Message:    flux.ErrorMessageJwtVerifyFailed,
+			}
+		}
+		ctx.SetAttribute(flux.XJwtSubject, cast.ToString(claims[j.Configs.subjectKey]))
+		ctx.SetAttribute(flux.XJwtIssuer, cast.ToString(claims[j.Configs.issuerKey]))
+		ctx.SetAttribute(flux.XJwtToken, tokenString)
+		ctx.SetVariable(JwtClaimsKey, map[string]interface{}(claims))
+		return next(ctx)
+	}
+}
+
+func (j *JwtVerificationFilter) lookupToken(ctx flux.Context) string {
+	var token string
+	if _, _, ok := pkg.LookupParseExpr(j.Configs.lookupToken); ok {
+		value, err := context.LookupContextByExpr(j.Configs.lookupToken, ctx)
+		if nil != err {
+			logger.WithContext(ctx).Warnw("Jwt lookup token", "lookup", j.Configs.lookupToken, "error", err)
+			return ""
+		}
+		token = cast.ToString(value)
+	} else {
+		token = ctx.Request().HeaderVar(j.Configs.lookupToken)
+	}
+	// Authorization: Bearer {token}
+	if len(token) > len("Bearer ") && strings.EqualFold(token[:len("Bearer ")], "Bearer ") {
+		token = token[len("Bearer "):]
+	}
+	return strings.TrimSpace(token)
+}
+
+func (j *JwtVerificationFilter) loadVerifyKey(ctx flux.Context, token *jwt.Token) (interface{}, error) {
+	claims := token.Claims.(jwt.MapClaims)
+	issuer, ok := claims[j.Configs.issuerKey]
+	if !ok {
+		return nil, ErrJwtIssuerNotFound
+	}
+	subject, ok := claims[j.Configs.subjectKey]
+	if !ok {
+		return nil, ErrJwtSubjectNotFound
+	}
+	iss, sub := cast.ToString(issuer), cast.ToString(subject)
+	cacheKey := token.Method.Alg() + ":" + iss + ":" + sub
+	if !j.Configs.cacheDisabled {
+		if key, ok := j.keys.Get(cacheKey); ok {
+			return key, nil
+		}
+	}
+	secret, err := j.Configs.SecretLoadFunc(ctx, iss, sub, claims)
+	if nil != err {
+		return nil, err
+	}
+	key, err := ParseJwtVerifyKey(token.Method, secret)
+	if nil != err {
+		return nil, err
+	}
+	if !j.Configs.cacheDisabled {
+		j.keys.Set(cacheKey, key)
+	}
+	return key, nil
+}
+
+// loadUpstreamSecret 通过后端服务加载签名密钥
+func (j *JwtVerificationFilter) loadUpstreamSecret(ctx flux.Context, issuer, subject string, claims jwt.MapClaims) (string, error) {
+	service := j.Configs.upstream
+	if flux.ProtoHttp == service.AttrRpcProto() {
+		text, err := ext.JSONMarshal(claims)
+		if nil != err {
+			return "", err
+		}
+		service.Arguments = []flux.Argument{
+			ext.NewStringArgumentWith("issuer", issuer),
+			ext.NewStringArgumentWith("subject", subject),
+			ext.NewStringArgumentWith("claims", string(text)),
+		}
+	} else {
+		service.Arguments = []flux.Argument{
+			ext.NewStringArgumentWith("issuer", issuer),
+			ext.NewStringArgumentWith("subject", subject),
+			ext.NewPrimitiveArgumentWithLoader(flux.JavaUtilMapClassName, "claims", func() flux.MTValue {
+				return flux.WrapStrMapMTValue(claims)
+			}),
+		}
+	}
+	resp, serr := backend.DoInvokeCodec(ctx, service)
+	if nil != serr {
+		return "", serr
+	}
+	if flux.StatusOK != resp.StatusCode {
+		if closer, ok := resp.Body.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return "", fmt.Errorf("jwt load secret, upstream status: %d", resp.StatusCode)
+	}
+	secret, err := backend.CastDecodeMTValueToString(flux.WrapObjectMTValue(resp.Body))
+	if nil != err {
+		return "", err
+	}
+	if "" == secret {
+		return "", errors.New("jwt load secret, upstream return empty secret")
+	}
+	return secret, nil
+}
+
+func (j *JwtVerificationFilter) newVerifyError(err error) *flux.ServeError {
+	if verr, ok := err.(*jwt.ValidationError); ok {
+		// 加载密钥过程的错误
+		if serr, ok := verr.Inner.(*flux.ServeError); ok {
+			return serr
+		}
+		if verr.Inner == ErrJwtIssuerNotFound || verr.Inner == ErrJwtSubjectNotFound || errors.Is(verr.Inner, ErrJwtMethodMismatch) {
+			return &flux.ServeError{
+				StatusCode: flux.StatusUnauthorized,
+				ErrorCode:  flux.ErrorCodeJwtInvalid,
+				Message:    flux.ErrorMessageJwtVerifyFailed,
+				Internal:   err,
+			}
+		}
+		switch {
+		case verr.Errors&jwt.ValidationErrorMalformed != 0:
+			return &flux.ServeError{
+				StatusCode: flux.StatusUnauthorized,
+				ErrorCode:  flux.ErrorCodeJwtInvalid,
+				Message:    flux.ErrorMessageJwtMalformed,
+				Internal:   err,
+			}
+		case verr.Errors&(jwt.ValidationErrorExpired|jwt.ValidationErrorNotValidYet) != 0:
+			return &flux.ServeError{
+				StatusCode: flux.StatusUnauthorized,
+				ErrorCode:  flux.ErrorCodeJwtInvalid,
+				Message:    flux.ErrorMessageJwtExpired,
+				Internal:   err,
+			}
+		case verr.Errors&jwt.ValidationErrorUnverifiable != 0:
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessageJwtSecretLoad,
+				Internal:   err,
+			}
+		}
+	}
+	return &flux.ServeError{
+		StatusCode: flux.StatusUnauthorized,
+		ErrorCode:  flux.ErrorCodeJwtInvalid,
+		Message:    flux.ErrorMessageJwtVerifyFailed,
+		Internal:   err,
+	}
+}
+
+// ParseJwtVerifyKey 根据密钥文本确定验证密钥：PEM格式为RS/PS/ES的公钥，其它为HS的原始密钥；
+// 密钥类型与Token声明的签名算法不一致时，返回错误。
+func ParseJwtVerifyKey(method jwt.SigningMethod, secret string) (interface{}, error) {
+	if strings.HasPrefix(strings.TrimSpace(secret), "-----BEGIN") {
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return jwt.ParseRSAPublicKeyFromPEM([]byte(secret))
+		case *jwt.SigningMethodECDSA:
+			return jwt.ParseECPublicKeyFromPEM([]byte(secret))
+		}
+	} else if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		return []byte(secret), nil
+	}
+	return nil, fmt.Errorf("%w, method: %s", ErrJwtMethodMismatch, method.Alg())
+}
+
+func newJwtUpstreamService(config *flux.Configuration) (flux.BackendService, error) {
+	proto := strings.ToUpper(config.GetString(JwtConfigKeyUpstreamProto))
+	uri, method := config.GetString(JwtConfigKeyUpstreamUri), config.GetString(JwtConfigKeyUpstreamMethod)
+	if "" == uri || "" == method {
+		return flux.BackendService{}, fmt.Errorf("jwt upstream config: %s, %s is required", JwtConfigKeyUpstreamUri, JwtConfigKeyUpstreamMethod)
+	}
+	service := flux.BackendService{
+		RemoteHost: config.GetString(JwtConfigKeyUpstreamHost),
+		Interface:  uri,
+		Method:     method,
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{
+				{Name: flux.ServiceAttrTagRpcProto, Value: proto},
+				{Name: flux.ServiceAttrTagRpcTimeout, Value: config.GetString(JwtConfigKeyUpstreamTimeout)},
+			},
+		},
+	}
+	switch proto {
+	case flux.ProtoHttp:
+		// Http协议：upstream-uri为完整的URL地址
+		u, err := url.Parse(uri)
+		if nil != err {
+			return service, fmt.Errorf("jwt upstream config: illegal http uri: %s, err: %w", uri, err)
+		}
+		service.Scheme = u.Scheme
+		service.Interface = u.Path
+		service.Method = strings.ToUpper(method)
+		if "" != u.Host {
+			service.RemoteHost = u.Host
+		}
+		if "" == service.Scheme {
+			service.Scheme = "http"
+		}
+		if "" == service.RemoteHost {
+			return service, fmt.Errorf("jwt upstream config: http host is required, uri: %s", uri)
+		}
+		if !pkg.StringSliceContains([]string{http.MethodGet, http.MethodPost, http.MethodPut}, service.Method) {
+			return service, fmt.Errorf("jwt upstream config: unsupported http method: %s", method)
+		}
+	case flux.ProtoDubbo:
+		// Dubbo协议：upstream-uri为接口名称
+	default:
+		return service, fmt.Errorf("jwt upstream config: unsupported protocol: %s", proto)
+	}
+	return service, nil
+}
@@ -0,0 +1,125 @@
+package filter
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/bytepowered/flux"
+	"github.com/bytepowered/flux/context"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestJwtVerificationFilter_DoFilter(t *testing.T) {
+	tester := assert.New(t)
+	filter := NewJwtVerificationFilter(JwtConfig{
+		SecretLoadFunc: func(ctx flux.Context, issuer, subject string, claims jwt.MapClaims) (string, error) {
+			tester.Equal("flux", issuer)
+			tester.Equal("yongjia", subject)
+			return "secret-of-" + subject, nil
+		},
+	})
+	tester.NoError(filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{})))
+	sign := func(secret string, exp time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "flux",
+			"sub": "yongjia",
+			"exp": exp.Unix(),
+		})
+		str, err := token.SignedString([]byte(secret))
+		tester.NoError(err)
+		return str
+	}
+	next := func(ctx flux.Context) *flux.ServeError {
+		return nil
+	}
+	cases := []struct {
+		token string
+		err   string
+	}{
+		{token: "", err: flux.ErrorMessageJwtMissingToken},
+		{token: "Bearer not-a-token", err: flux.ErrorMessageJwtMalformed},
+		{token: "Bearer " + sign("secret-of-yongjia", time.Now().Add(-time.Minute)), err: flux.ErrorMessageJwtExpired},
+		{token: "Bearer " + sign("bad-secret", time.Now().Add(time.Minute)), err: flux.ErrorMessageJwtVerifyFailed},
+		{token: "Bearer " + sign("secret-of-yongjia", time.Now().Add(time.Minute))},
+	}
+	for _, c := range cases {
+		ctx := context.NewMockContext(map[string]interface{}{
+			flux.HeaderAuthorization: c.token,
+		})
+		serr := filter.DoFilter(next)(ctx)
+		if "" == c.err {
+			tester.Nil(serr)
+			tester.Equal("yongjia", ctx.Attribute(flux.XJwtSubject, ""))
+			tester.Equal("flux", ctx.Attribute(flux.XJwtIssuer, ""))
+			_, ok := ctx.GetVariable(JwtClaimsKey)
+			tester.True(ok)
+		} else {
+			tester.NotNil(serr)
+			tester.Equal(c.err, serr.Message)
+			tester.Equal(flux.StatusUnauthorized, serr.StatusCode)
+		}
+	}
+}
+
+func TestJwtVerificationFilter_MethodConfusion(t *testing.T) {
+	tester := assert.New(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	tester.NoError(err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	tester.NoError(err)
+	publicPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	filter := NewJwtVerificationFilter(JwtConfig{
+		SecretLoadFunc: func(ctx flux.Context, issuer, subject string, claims jwt.MapClaims) (string, error) {
+			if "rsa" == subject {
+				return publicPEM, nil
+			}
+			return "secret-of-" + subject, nil
+		},
+	})
+	tester.NoError(filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		JwtConfigKeyValidMethods: []string{"HS256", "RS256"},
+	})))
+	sign := func(method jwt.SigningMethod, subject string, key interface{}) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{
+			"iss": "flux",
+			"sub": subject,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		str, err := token.SignedString(key)
+		tester.NoError(err)
+		return str
+	}
+	next := func(ctx flux.Context) *flux.ServeError {
+		return nil
+	}
+	cases := []struct {
+		token string
+		valid bool
+	}{
+		{token: sign(jwt.SigningMethodRS256, "rsa", rsaKey), valid: true},
+		{token: sign(jwt.SigningMethodHS256, "yongjia", []byte("secret-of-yongjia")), valid: true},
+		// 使用公钥文本作为HMAC密钥签名
+		{token: sign(jwt.SigningMethodHS256, "rsa", []byte(publicPEM)), valid: false},
+		// 未配置的签名算法
+		{token: sign(jwt.SigningMethodHS512, "yongjia", []byte("secret-of-yongjia")), valid: false},
+		// 非PEM密钥不能用于验证RSA签名
+		{token: sign(jwt.SigningMethodRS256, "yongjia", rsaKey), valid: false},
+	}
+	for _, c := range cases {
+		ctx := context.NewMockContext(map[string]interface{}{
+			flux.HeaderAuthorization: "Bearer " + c.token,
+		})
+		serr := filter.DoFilter(next)(ctx)
+		if c.valid {
+			tester.Nil(serr)
+		} else {
+			tester.NotNil(serr)
+			tester.Equal(flux.StatusUnauthorized, serr.StatusCode)
+			tester.Equal(flux.ErrorMessageJwtVerifyFailed, serr.Message)
+		}
+	}
+}
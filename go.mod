@@ -7,8 +7,11 @@ require (
 	github.com/apache/dubbo-go v1.5.1
 	github.com/apache/dubbo-go-hessian2 v1.7.0
 	github.com/bwmarrin/snowflake v0.3.0
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/dubbogo/go-zookeeper v1.0.1
+	github.com/golang/protobuf v1.3.2
 	github.com/google/uuid v1.1.1
+	github.com/jhump/protoreflect v1.5.0
 	github.com/jinzhu/copier v0.0.0-20190924061706-b57f9002281a // indirect
 	github.com/json-iterator/go v1.1.9
 	github.com/labstack/echo/v4 v4.1.16
@@ -20,5 +23,6 @@ require (
 	go.uber.org/zap v1.15.0
 	golang.org/x/net v0.0.0-20200602114024-627f9648deb9 // indirect
 	golang.org/x/sys v0.0.0-20200602225109-6fdc65e7d980 // indirect
+	google.golang.org/grpc v1.23.0
 	gopkg.in/yaml.v2 v2.3.0
 )
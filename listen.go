@@ -22,6 +22,7 @@ const (
 	MIMEApplicationJSON            = "application/json"
 	MIMEApplicationJSONCharsetUTF8 = MIMEApplicationJSON + "; " + charsetUTF8
 	MIMEApplicationForm            = "application/x-www-form-urlencoded"
+	MIMETextEventStream            = "text/event-stream"
 )
 
 // Headers
@@ -30,14 +31,17 @@ const (
 	HeaderAcceptEncoding      = "Accept-Encoding"
 	HeaderAllow               = "Allow"
 	HeaderAuthorization       = "Authorization"
+	HeaderCacheControl        = "Cache-Control"
 	HeaderContentDisposition  = "Content-Disposition"
 	HeaderContentEncoding     = "Content-Encoding"
 	HeaderContentLength       = "Content-Length"
 	HeaderContentType         = "Content-Type"
+	HeaderETag                = "ETag"
 	HeaderCookie              = "Cookie"
 	HeaderSetCookie           = "Set-Cookie"
 	HeaderIfModifiedSince     = "If-Modified-Since"
 	HeaderLastModified        = "Last-Modified"
+	HeaderLastEventID         = "Last-Event-ID"
 	HeaderLocation            = "Location"
 	HeaderUpgrade             = "Upgrade"
 	HeaderVary                = "Vary"
@@ -87,6 +91,7 @@ const (
 	StatusAccessDenied = http.StatusForbidden
 	StatusServerError  = http.StatusInternalServerError
 	StatusBadGateway   = http.StatusBadGateway
+	StatusTimeout      = http.StatusGatewayTimeout
 )
 
 // Web interfaces defines
@@ -110,6 +115,12 @@ type (
 	WebResponseWriter func(webc WebContext, header http.Header, status int, body interface{}, error *ServeError) error
 )
 
+// WebServePayload 可自行写入WebContext的响应数据；
+// 例如升级为WebSocket连接后，接管客户端连接并双向转发数据。
+type WebServePayload interface {
+	ServeWeb(webc WebContext) error
+}
+
 // WebContext 定义封装Web框架的RequestContext的接口；
 // 用于 WebHandler，WebInterceptor 实现Web请求处理；
 type WebContext interface {
@@ -257,6 +268,9 @@ type ListenServer interface {
 	// AddHttpHandler 添加http标准请求路由处理函数及其中间件
 	AddHttpHandler(method, pattern string, h http.Handler, m ...func(http.Handler) http.Handler)
 
+	// RemoveHandler 删除请求路由处理函数；删除后，对应路由的请求将按路由不存在处理
+	RemoveHandler(method, pattern string)
+
 	// Write 处理并写入业务响应数据；如果发生错误，将尝试通过 WriteError 再次写入错误响应数据；
 	Write(webc WebContext, header http.Header, status int, data interface{}) error
 
@@ -6,7 +6,6 @@ import (
 	"github.com/bytepowered/flux/ext"
 	"github.com/bytepowered/flux/logger"
 	"io"
-	"io/ioutil"
 	"net/http"
 )
 
@@ -38,6 +37,13 @@ func DefaultServerErrorHandler(webc flux.WebContext, err error) {
 
 func DefaultResponseWriter(webc flux.WebContext, header http.Header, status int, body interface{}, serr *flux.ServeError) error {
 	id := webc.Variable(flux.HeaderXRequestId).(string)
+	// 自行写入WebContext的响应数据，例如WebSocket连接
+	if sp, ok := body.(flux.WebServePayload); ok && nil == serr {
+		if err := sp.ServeWeb(webc); nil != err {
+			logger.With(id).Errorw("Http-ResponseWriter, serve payload", "error", err)
+		}
+		return nil
+	}
 	SetupResponseDefaults(webc, id, header)
 	var payload interface{}
 	if nil != serr {
@@ -52,27 +58,27 @@ func DefaultResponseWriter(webc flux.WebContext, header http.Header, status int,
 	} else {
 		payload = body
 	}
-	// 序列化payload
-	var data []byte
+	// 流数据：直接复制到客户端，保留后端服务响应的Content-Type等Header
 	if r, ok := payload.(io.Reader); ok {
 		if c, ok := r.(io.Closer); ok {
 			defer func() {
 				_ = c.Close()
 			}()
 		}
-		if bytes, err := ioutil.ReadAll(r); nil != err {
-			logger.With(id).Errorw("Http-ResponseWriter, read body", "error", err)
-			return err
-		} else {
-			data = bytes
-		}
-	} else {
-		if bytes, err := ext.JSONMarshal(payload); nil != err {
-			logger.With(id).Errorw("Http-ResponseWriter, serialize to json", "body", payload, "error", err)
-			return err
+		// 写入Http响应发生的错误，没必要向上抛出Error错误处理。因为已无法通过WriteError写到客户端
+		if err := WriteHttpStream(webc, status, flux.MIMEApplicationJSON, r); nil != err {
+			logger.With(id).Errorw("Http-ResponseWriter, write stream", "error", err)
 		} else {
-			data = bytes
+			logger.With(id).Infow("Http-ResponseWriter, logging stream",
+				"content-type", header.Get(flux.HeaderContentType), "content-length", header.Get(flux.HeaderContentLength))
 		}
+		return nil
+	}
+	// 序列化payload
+	data, err := ext.JSONMarshal(payload)
+	if nil != err {
+		logger.With(id).Errorw("Http-ResponseWriter, serialize to json", "body", payload, "error", err)
+		return err
 	}
 	logger.With(id).Infow("Http-ResponseWriter, logging", "data", string(data))
 	// 写入Http响应发生的错误，没必要向上抛出Error错误处理。因为已无法通过WriteError写到客户端
@@ -90,12 +96,24 @@ func WriteHttpResponse(webc flux.WebContext, statusCode int, contentType string,
 	return err
 }
 
+func WriteHttpStream(webc flux.WebContext, statusCode int, contentType string, reader io.Reader) error {
+	err := webc.WriteStream(statusCode, contentType, reader)
+	if nil != err {
+		return fmt.Errorf("write http stream: %w", err)
+	}
+	return err
+}
+
 func SetupResponseDefaults(webc flux.WebContext, requestId string, header http.Header) {
 	webc.SetResponseHeader(flux.HeaderXRequestId, requestId)
 	webc.SetResponseHeader(flux.HeaderServer, "Flux/Gateway")
 	webc.SetResponseHeader(flux.HeaderContentType, flux.MIMEApplicationJSON)
-	// 允许Override默认Header
+	// 允许Override默认Header；Content-Type只保留一个值
 	for k, v := range header {
+		if flux.HeaderContentType == http.CanonicalHeaderKey(k) && len(v) > 0 {
+			webc.SetResponseHeader(k, v[0])
+			continue
+		}
 		for _, iv := range v {
 			webc.AddResponseHeader(k, iv)
 		}
@@ -2,9 +2,17 @@ package main
 
 import (
 	"github.com/bytepowered/flux"
+	_ "github.com/bytepowered/flux/backend/aggregate"
 	_ "github.com/bytepowered/flux/backend/dubbo"
 	_ "github.com/bytepowered/flux/backend/echo"
+	_ "github.com/bytepowered/flux/backend/fcgi"
+	_ "github.com/bytepowered/flux/backend/grpc"
 	_ "github.com/bytepowered/flux/backend/http"
+	_ "github.com/bytepowered/flux/backend/jsonrpc"
+	_ "github.com/bytepowered/flux/backend/mock"
+	_ "github.com/bytepowered/flux/backend/pipeline"
+	_ "github.com/bytepowered/flux/backend/static"
+	_ "github.com/bytepowered/flux/backend/websocket"
 	"github.com/bytepowered/flux/boot"
 	_ "github.com/bytepowered/flux/webserver"
 )
@@ -2,6 +2,10 @@ package flux
 
 import (
 	"github.com/spf13/cast"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -38,10 +42,14 @@ const (
 	ScopeHeader = "HEADER"
 	// 获取Header全部参数
 	ScopeHeaderMap = "HEADER_MAP"
+	// 只从Cookie中读取
+	ScopeCookie = "COOKIE"
 	// 获取Http Attributes的单个参数
 	ScopeAttr = "ATTR"
 	// 获取Http Attributes的Map结果
 	ScopeAttrs = "ATTRS"
+	// 获取Context.Variable的值，支持以"."分隔的嵌套路径，例如：user.id
+	ScopeValue = "VALUE"
 	// 获取Body数据
 	ScopeBody = "BODY"
 	// 获取Request元数据
@@ -63,6 +71,20 @@ const (
 	ProtoGRPC  = "GRPC"
 	ProtoHttp  = "HTTP"
 	ProtoEcho  = "ECHO"
+	// 聚合多个BackendService的协议
+	ProtoAggregate = "AGGREGATE"
+	// 链式调用多个BackendService的协议
+	ProtoPipeline = "PIPELINE"
+	// 按Endpoint扩展配置返回模拟响应的协议
+	ProtoMock = "MOCK"
+	// 代理WebSocket连接的协议
+	ProtoWebSocket = "WEBSOCKET"
+	// 托管静态文件和单页应用的协议
+	ProtoStatic = "STATIC"
+	// JSON-RPC 2.0 over Http的协议
+	ProtoJsonRpc = "JSONRPC"
+	// FastCGI协议，例如PHP-FPM服务
+	ProtoFastCGI = "FCGI"
 )
 
 // ServiceAttributes
@@ -81,6 +103,10 @@ const (
 	EndpointAttrTagAuthorize  = "authorize" // 标识Endpoint访问是否需要授权
 	EndpointAttrTagServerId   = "serverid"  // 标识Endpoint绑定到哪个ListenServer服务
 	EndpointAttrTagBizId      = "bizid"     // 标识Endpoint绑定到业务标识
+	EndpointAttrTagWeight     = "weight"    // 标识Endpoint版本的流量权重（百分比），未声明的版本均分剩余权重
+	EndpointAttrTagSticky     = "sticky"    // 标识Endpoint版本粘性选择的Lookup表达式，例如：header:X-User-Id
+	EndpointAttrTagTimeout    = "timeout"   // 标识Endpoint请求的总超时时间，例如：3s
+	EndpointAttrTagRouteRule  = "routerule" // 标识Endpoint版本的选择规则，可声明多个
 )
 
 type (
@@ -101,6 +127,9 @@ type Argument struct {
 	HttpName  string     `json:"httpName" yaml:"httpName"`   // 映射Http的参数Key
 	HttpScope string     `json:"httpScope" yaml:"httpScope"` // 映射Http参数值域
 	Fields    []Argument `json:"fields" yaml:"fields"`       // 子结构字段
+	// 参数值在后端服务请求中的位置：[PATH, QUERY, HEADER, COOKIE, BODY]；
+	// 未声明时，由后端协议决定，例如Http协议：GET请求为QUERY，其它为BODY
+	TargetScope string `json:"targetScope" yaml:"targetScope"`
 	// helper
 	ValueLoader   func() MTValue     `json:"-"`
 	LookupFunc    ArgumentLookupFunc `json:"-"`
@@ -276,9 +305,30 @@ func (e Endpoint) AttrAuthorize() bool {
 	return e.GetAttr(EndpointAttrTagAuthorize).GetBool()
 }
 
+// AttrWeight 返回声明的流量权重；未声明时返回false
+func (e Endpoint) AttrWeight() (int, bool) {
+	attr := e.GetAttr(EndpointAttrTagWeight)
+	if nil == attr.Value {
+		return 0, false
+	}
+	if w := attr.GetInt(); w > 0 {
+		return w, true
+	}
+	return 0, true
+}
+
+func (e Endpoint) AttrSticky() string {
+	return e.GetAttr(EndpointAttrTagSticky).GetString()
+}
+
+func (e Endpoint) AttrTimeout() string {
+	return e.GetAttr(EndpointAttrTagTimeout).GetString()
+}
+
 // Multi version Endpoint
 type MultiEndpoint struct {
 	endpoint      map[string]*Endpoint // 各版本数据
+	versions      []string             // 已排序的版本列表，保证权重选择的顺序稳定
 	*sync.RWMutex                      // 读写锁
 }
 
@@ -287,35 +337,72 @@ func NewMultiEndpoint(endpoint *Endpoint) *MultiEndpoint {
 		endpoint: map[string]*Endpoint{
 			endpoint.Version: endpoint,
 		},
-		RWMutex: new(sync.RWMutex),
+		versions: []string{endpoint.Version},
+		RWMutex:  new(sync.RWMutex),
 	}
 }
 
 // Find find endpoint by version
 func (m *MultiEndpoint) LookupByVersion(version string) (*Endpoint, bool) {
 	m.RLock()
-	if "" == version || 1 == len(m.endpoint) {
+	defer m.RUnlock()
+	if 1 == len(m.endpoint) {
 		rv := m.random()
-		m.RUnlock()
+		return rv, nil != rv
+	}
+	if "" == version {
+		rv := m.weighted("")
 		return rv, nil != rv
 	}
 	v, ok := m.endpoint[version]
-	m.RUnlock()
 	return v, ok
 }
 
+// LookupByWeight 按各版本的权重属性选择Endpoint；权重为流量百分比，未声明权重的版本均分剩余的百分比。
+// stickyKey不为空时，按其Hash值选择，权重不变时同一Key总是选中相同版本。
+// 注意：Hash空间按版本排序依次划分区间，调整权重时其后各版本的区间边界都会移动；
+// 只有调整相邻两个版本之间的权重（例如只有两个版本）时，才只有权重变化部分的Key会改变版本。
+// 所有版本权重均为0时，各版本均分流量。
+func (m *MultiEndpoint) LookupByWeight(stickyKey string) (*Endpoint, bool) {
+	m.RLock()
+	rv := m.weighted(stickyKey)
+	m.RUnlock()
+	return rv, nil != rv
+}
+
+// AttrSticky 返回各版本中首个声明的粘性选择Lookup表达式
+func (m *MultiEndpoint) AttrSticky() string {
+	m.RLock()
+	defer m.RUnlock()
+	for _, v := range m.versions {
+		if expr := m.endpoint[v].AttrSticky(); "" != expr {
+			return expr
+		}
+	}
+	return ""
+}
+
 func (m *MultiEndpoint) Update(version string, endpoint *Endpoint) {
 	m.Lock()
 	m.endpoint[version] = endpoint
+	m.sortVersions()
 	m.Unlock()
 }
 
 func (m *MultiEndpoint) Delete(version string) {
 	m.Lock()
 	delete(m.endpoint, version)
+	m.sortVersions()
 	m.Unlock()
 }
 
+// IsEmpty 判断是否已不包含任何版本的Endpoint
+func (m *MultiEndpoint) IsEmpty() bool {
+	m.RLock()
+	defer m.RUnlock()
+	return 0 == len(m.endpoint)
+}
+
 func (m *MultiEndpoint) RandomVersion() *Endpoint {
 	m.RLock()
 	rv := m.random()
@@ -330,6 +417,103 @@ func (m *MultiEndpoint) random() *Endpoint {
 	return nil
 }
 
+// weightedSlots 权重选择的固定Hash空间
+const weightedSlots = 10000
+
+func (m *MultiEndpoint) weighted(stickyKey string) *Endpoint {
+	size := len(m.versions)
+	if 0 == size {
+		return nil
+	} else if 1 == size {
+		return m.endpoint[m.versions[0]]
+	}
+	weights, total := m.weights()
+	if 0 == total {
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = size
+	}
+	// 在固定的Hash空间中选择，按各版本权重的累计比例划分区间
+	var n int
+	if "" != stickyKey {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(stickyKey))
+		n = int(h.Sum32() % weightedSlots)
+	} else {
+		n = rand.Intn(weightedSlots)
+	}
+	cumulative := 0
+	for i, v := range m.versions {
+		cumulative += weights[i]
+		if n*total < cumulative*weightedSlots {
+			return m.endpoint[v]
+		}
+	}
+	return m.endpoint[m.versions[size-1]]
+}
+
+// weights 返回各版本的权重；未声明权重的版本，均分声明权重之外剩余的百分比
+func (m *MultiEndpoint) weights() ([]int, int) {
+	weights := make([]int, len(m.versions))
+	declared, undeclared := 0, 0
+	for i, v := range m.versions {
+		if w, ok := m.endpoint[v].AttrWeight(); ok {
+			weights[i] = w
+			declared += w
+		} else {
+			weights[i] = -1
+			undeclared++
+		}
+	}
+	share := 0
+	if undeclared > 0 && declared < 100 {
+		share = (100 - declared) / undeclared
+	}
+	total := 0
+	for i, w := range weights {
+		if w < 0 {
+			weights[i] = share
+		}
+		total += weights[i]
+	}
+	return weights, total
+}
+
+func (m *MultiEndpoint) sortVersions() {
+	versions := make([]string, 0, len(m.endpoint))
+	for v := range m.endpoint {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareVersion(versions[i], versions[j]) < 0
+	})
+	m.versions = versions
+}
+
+// CompareVersion 按版本号的各段比较版本，数字段按数值比较，例如：2.0 < 10.0
+func CompareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		if nil == aerr && nil == berr {
+			if an == bn {
+				continue
+			}
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(as[i], bs[i])
+	}
+	return len(as) - len(bs)
+}
+
 func (m *MultiEndpoint) ToSerializable() map[string]*Endpoint {
 	copies := make(map[string]*Endpoint)
 	m.RLock()
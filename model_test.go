@@ -1,6 +1,7 @@
 package flux
 
 import (
+	"fmt"
 	assert2 "github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -106,3 +107,84 @@ func TestParseJsonTextToEndpoint(t *testing.T) {
 	assert.Equal("year", endpoint.Permission.Arguments[1].HttpName)
 	assert.Equal("PRIMITIVE", endpoint.Permission.Arguments[1].Type)
 }
+
+func TestMultiEndpoint_LookupByWeight(t *testing.T) {
+	assert := assert2.New(t)
+	newEndpoint := func(version string, weight int) *Endpoint {
+		return &Endpoint{
+			Version: version,
+			EmbeddedAttributes: EmbeddedAttributes{
+				Attributes: []Attribute{{Name: EndpointAttrTagWeight, Value: weight}},
+			},
+		}
+	}
+	mep := NewMultiEndpoint(newEndpoint("1.0", 0))
+	mep.Update("2.0", newEndpoint("2.0", 100))
+	// 权重为0的版本不会被选中
+	for i := 0; i < 100; i++ {
+		ep, ok := mep.LookupByWeight("")
+		assert.True(ok)
+		assert.Equal("2.0", ep.Version)
+	}
+	// 相同StickyKey总是选中同一版本
+	mep.Update("1.0", newEndpoint("1.0", 50))
+	mep.Update("2.0", newEndpoint("2.0", 50))
+	for _, key := range []string{"u-1", "u-2", "u-3"} {
+		first, _ := mep.LookupByWeight(key)
+		for i := 0; i < 10; i++ {
+			ep, _ := mep.LookupByWeight(key)
+			assert.Equal(first.Version, ep.Version)
+		}
+	}
+	// 指定版本
+	ep, ok := mep.LookupByVersion("1.0")
+	assert.True(ok)
+	assert.Equal("1.0", ep.Version)
+	mep.Delete("1.0")
+	ep, ok = mep.LookupByWeight("u-1")
+	assert.True(ok)
+	assert.Equal("2.0", ep.Version)
+}
+
+func TestMultiEndpoint_LookupByWeightCanary(t *testing.T) {
+	assert := assert2.New(t)
+	newEndpoint := func(version string, attrs ...Attribute) *Endpoint {
+		return &Endpoint{Version: version, EmbeddedAttributes: EmbeddedAttributes{Attributes: attrs}}
+	}
+	canary := func(weight int) *Endpoint {
+		return newEndpoint("10.0", Attribute{Name: EndpointAttrTagWeight, Value: weight})
+	}
+	// 只为灰度版本声明权重，未声明的版本使用剩余的权重
+	mep := NewMultiEndpoint(newEndpoint("2.0"))
+	mep.Update("10.0", canary(5))
+	keys := make([]string, 2000)
+	hits := make(map[string]bool)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("u-%d", i)
+		ep, _ := mep.LookupByWeight(keys[i])
+		hits[keys[i]] = "10.0" == ep.Version
+	}
+	count := 0
+	for _, hit := range hits {
+		if hit {
+			count++
+		}
+	}
+	assert.InDelta(100, count, 50)
+	// 增加灰度权重时，已选中灰度版本的Key保持不变
+	mep.Update("10.0", canary(50))
+	for _, key := range keys {
+		if hits[key] {
+			ep, _ := mep.LookupByWeight(key)
+			assert.Equal("10.0", ep.Version)
+		}
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	assert := assert2.New(t)
+	assert.True(CompareVersion("2.0", "10.0") < 0)
+	assert.True(CompareVersion("1.10", "1.9") > 0)
+	assert.True(CompareVersion("1.0", "1.0.1") < 0)
+	assert.Equal(0, CompareVersion("v1", "v1"))
+}
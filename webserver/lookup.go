@@ -30,12 +30,19 @@ func LookupValue(scope, key string, webc flux.WebContext) string {
 		return webc.FormVar(key)
 	case flux.ScopeHeader:
 		return webc.HeaderVar(key)
+	case flux.ScopeCookie:
+		if cookie := webc.CookieVar(key); nil != cookie {
+			return cookie.Value
+		}
+		return ""
 	case flux.ScopeRequest:
 		switch strings.ToLower(key) {
 		case "method":
 			return webc.Method()
 		case "uri":
 			return webc.URI()
+		case "address":
+			return webc.Address()
 		}
 		return webc.Method()
 	case flux.ScopeParam:
@@ -142,8 +142,41 @@ func (c *AdaptWebContext) Write(statusCode int, contentType string, bytes []byte
 	return c.echoc.Blob(statusCode, contentType, bytes)
 }
 
+// WriteStream 以流方式写入响应数据，每次写入后Flush到客户端；客户端断开连接时停止写入
 func (c *AdaptWebContext) WriteStream(statusCode int, contentType string, reader io.Reader) error {
-	return c.echoc.Stream(statusCode, contentType, reader)
+	resp := c.echoc.Response()
+	if "" == resp.Header().Get(echo.HeaderContentType) {
+		resp.Header().Set(echo.HeaderContentType, contentType)
+	}
+	resp.WriteHeader(statusCode)
+	// 先Flush响应Header，客户端无需等待首个数据块即可建立流
+	flusher, _ := resp.Writer.(http.Flusher)
+	if nil != flusher {
+		flusher.Flush()
+	}
+	done := c.echoc.Request().Context().Done()
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-done:
+			return c.echoc.Request().Context().Err()
+		default:
+		}
+		n, rerr := reader.Read(buf)
+		if n > 0 {
+			if _, werr := resp.Write(buf[:n]); nil != werr {
+				return werr
+			}
+			if nil != flusher {
+				flusher.Flush()
+			}
+		}
+		if io.EOF == rerr {
+			return nil
+		} else if nil != rerr {
+			return rerr
+		}
+	}
 }
 
 func (c *AdaptWebContext) Send(webc flux.WebContext, header http.Header, status int, data interface{}) error {
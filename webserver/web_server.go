@@ -15,6 +15,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 )
 
 const (
@@ -98,6 +99,7 @@ type AdaptWebServer struct {
 	tlsCertFile     string
 	tlsKeyFile      string
 	address         string
+	routes          sync.Map // method#pattern -> echo.HandlerFunc
 }
 
 func (w *AdaptWebServer) Init(opts *flux.Configuration) error {
@@ -172,7 +174,7 @@ func (w *AdaptWebServer) AddHandler(method, pattern string, h flux.WebHandler, m
 	for i, mi := range m {
 		wms[i] = AdaptWebInterceptor(mi).AdaptFunc
 	}
-	w.server.Add(method, toRoutePattern(pattern), AdaptWebRouteHandler(h).AdaptFunc, wms...)
+	w.addRoute(method, pattern, AdaptWebRouteHandler(h).AdaptFunc, wms)
 }
 
 func (w *AdaptWebServer) AddHttpHandler(method, pattern string, h http.Handler, m ...func(http.Handler) http.Handler) {
@@ -180,7 +182,31 @@ func (w *AdaptWebServer) AddHttpHandler(method, pattern string, h http.Handler,
 	for i, mf := range m {
 		wms[i] = echo.WrapMiddleware(mf)
 	}
-	w.server.Add(method, toRoutePattern(pattern), echo.WrapHandler(h), wms...)
+	w.addRoute(method, pattern, echo.WrapHandler(h), wms)
+}
+
+func (w *AdaptWebServer) RemoveHandler(method, pattern string) {
+	w.routes.Delete(method + "#" + toRoutePattern(pattern))
+}
+
+// addRoute 路由处理函数保存在动态路由表中，echo路由只注册一次分发函数；
+// echo不支持删除路由，删除时从路由表移除，分发函数按路由不存在处理。
+func (w *AdaptWebServer) addRoute(method, pattern string, h echo.HandlerFunc, wms []echo.MiddlewareFunc) {
+	for i := len(wms) - 1; i >= 0; i-- {
+		h = wms[i](h)
+	}
+	routePattern := toRoutePattern(pattern)
+	key := method + "#" + routePattern
+	if _, loaded := w.routes.LoadOrStore(key, h); loaded {
+		w.routes.Store(key, h)
+		return
+	}
+	w.server.Add(method, routePattern, func(c echo.Context) error {
+		if h, ok := w.routes.Load(key); ok {
+			return h.(echo.HandlerFunc)(c)
+		}
+		return echo.NotFoundHandler(c)
+	})
 }
 
 func (w *AdaptWebServer) Router() interface{} {
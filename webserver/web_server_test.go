@@ -0,0 +1,55 @@
+package webserver
+
+import (
+	"github.com/bytepowered/flux"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdaptWebServer_RemoveHandler(t *testing.T) {
+	tester := assert.New(t)
+	server := NewAdaptWebServer(flux.NewConfigurationOfMap(map[string]interface{}{
+		"features": map[string]interface{}{},
+	}))
+	server.AddHandler(http.MethodGet, "/api/{id}", func(webc flux.WebContext) error {
+		return webc.Write(http.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, []byte(webc.PathVar("id")))
+	})
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Router().(*echo.Echo).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/2020", nil))
+		return rec
+	}
+	rec := serve()
+	tester.Equal(http.StatusOK, rec.Code)
+	tester.Equal("2020", rec.Body.String())
+	server.RemoveHandler(http.MethodGet, "/api/{id}")
+	tester.Equal(http.StatusNotFound, serve().Code)
+	// 重新注册
+	server.AddHandler(http.MethodGet, "/api/{id}", func(webc flux.WebContext) error {
+		return webc.Write(http.StatusAccepted, flux.MIMEApplicationJSONCharsetUTF8, nil)
+	})
+	tester.Equal(http.StatusAccepted, serve().Code)
+}
+
+func TestAdaptWebContext_WriteStream(t *testing.T) {
+	tester := assert.New(t)
+	server := NewAdaptWebServer(flux.NewConfigurationOfMap(map[string]interface{}{
+		"features": map[string]interface{}{},
+	}))
+	server.AddHandler(http.MethodGet, "/download", func(webc flux.WebContext) error {
+		webc.SetResponseHeader(flux.HeaderContentType, "text/csv")
+		webc.SetResponseHeader("Content-Disposition", `attachment; filename="export.csv"`)
+		return webc.WriteStream(http.StatusOK, flux.MIMEApplicationJSON, strings.NewReader("id,name\n1,flux\n"))
+	})
+	rec := httptest.NewRecorder()
+	server.Router().(*echo.Echo).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download", nil))
+	tester.Equal(http.StatusOK, rec.Code)
+	tester.Equal("text/csv", rec.Header().Get(flux.HeaderContentType))
+	tester.Equal(`attachment; filename="export.csv"`, rec.Header().Get("Content-Disposition"))
+	tester.Equal("id,name\n1,flux\n", rec.Body.String())
+	tester.True(rec.Flushed)
+}